@@ -0,0 +1,339 @@
+package api
+
+import (
+	"autobid/config"
+	"autobid/dashboard"
+	"autobid/pricing"
+	"autobid/scanner"
+	"autobid/store"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	proxyTimeout = 10 * time.Second
+	alertsWindow = 24 * time.Hour // default of ?since
+)
+
+// Tracker is what the scanner has evaluated recently, see dashboard.Dashboard
+type Tracker interface {
+	Rows(now time.Time) []dashboard.Row
+}
+
+type Alerts interface {
+	Alerts(from, to time.Time) ([]store.Alert, error)
+}
+
+// Settings is the running config, see config.Watcher
+type Settings interface {
+	Current() *config.Config
+	Set(values map[string]any) ([]config.Change, error)
+}
+
+type Options struct {
+	Token      string // required, sent as "Authorization: Bearer <token>"
+	Tracker    Tracker
+	Engine     *scanner.Engine // floors are quoted with its rules
+	Proxies    []*url.URL
+	CheckProxy func(ctx context.Context, proxy *url.URL) (ip string, err error)
+	Alerts     Alerts // optional, /api/alerts answers 404 without it
+	Settings   Settings
+}
+
+type API struct {
+	opt *Options
+	mux *http.ServeMux
+}
+
+func New(opt *Options) (*API, error) {
+	if opt.Token == "" {
+		return nil, fmt.Errorf("api token is empty")
+	}
+	a := &API{opt: opt, mux: http.NewServeMux()}
+	a.mux.HandleFunc("GET /api/auctions", a.auctions)
+	a.mux.HandleFunc("GET /api/opportunities", a.opportunities)
+	a.mux.HandleFunc("GET /api/floors", a.floors)
+	a.mux.HandleFunc("GET /api/proxies", a.proxies)
+	a.mux.HandleFunc("GET /api/alerts", a.alerts)
+	a.mux.HandleFunc("GET /api/thresholds", a.thresholds)
+	a.mux.HandleFunc("PATCH /api/thresholds", a.setThresholds)
+	a.mux.HandleFunc("GET /api/watchlist", a.watchlist)
+	a.mux.HandleFunc("PUT /api/watchlist", a.setWatchlist)
+	return a, nil
+}
+
+// ServeHTTP rejects requests without the token before routing them, so
+// unknown paths do not reveal anything either
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.opt.Token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "missing or wrong bearer token")
+		return
+	}
+	a.mux.ServeHTTP(w, r)
+}
+
+type opportunity struct {
+	Kind          string     `json:"kind"`
+	Strategy      string     `json:"strategy"`
+	Market        string     `json:"market"`
+	SellMarket    string     `json:"sell_market"`
+	ID            string     `json:"id"`
+	GiftID        int        `json:"gift_id,omitempty"`
+	Name          string     `json:"name"`
+	Num           int        `json:"num"`
+	Model         string     `json:"model"`
+	Backdrop      string     `json:"backdrop"`
+	Symbol        string     `json:"symbol"`
+	Price         float64    `json:"price"`
+	Floor         float64    `json:"floor"`
+	FloorSource   string     `json:"floor_source"`
+	ProfitTon     float64    `json:"profit_ton"`
+	ProfitPercent float64    `json:"profit_percent"`
+	Fee           float64    `json:"fee"`
+	EndsAt        *time.Time `json:"ends_at,omitempty"`
+	BuyURL        string     `json:"buy_url"`
+	FoundAt       time.Time  `json:"found_at"`
+	Alerted       bool       `json:"alerted"`
+	Reason        string     `json:"reason,omitempty"` // why no alert was sent
+}
+
+func opportunityOf(r dashboard.Row) opportunity {
+	o := opportunity{
+		Kind:          r.Kind,
+		Strategy:      r.Strategy,
+		Market:        r.Market,
+		SellMarket:    r.SellMarket,
+		ID:            r.ID,
+		GiftID:        r.GiftID,
+		Name:          r.Name,
+		Num:           r.Num,
+		Model:         r.Model,
+		Backdrop:      r.Backdrop,
+		Symbol:        r.Symbol,
+		Price:         r.Price,
+		Floor:         r.Profit.Floor,
+		FloorSource:   r.Quote.Source,
+		ProfitTon:     r.Profit.Ton,
+		ProfitPercent: r.Profit.Percentage * 100,
+		Fee:           r.Fee,
+		BuyURL:        r.BuyURL,
+		FoundAt:       r.FoundAt,
+		Alerted:       r.Reason == "",
+		Reason:        r.Reason,
+	}
+	if !r.EndsAt.IsZero() {
+		o.EndsAt = &r.EndsAt
+	}
+	return o
+}
+
+// every evaluation still tracked, ?strategy= and ?alerted=true narrow it down
+func (a *API) opportunities(w http.ResponseWriter, r *http.Request) {
+	strategy := r.URL.Query().Get("strategy")
+	alerted := r.URL.Query().Get("alerted") == "true"
+	list := []opportunity{}
+	for _, row := range a.opt.Tracker.Rows(time.Now()) {
+		if strategy != "" && row.Strategy != strategy || alerted && row.Reason != "" {
+			continue
+		}
+		list = append(list, opportunityOf(row))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+type auction struct {
+	GiftID     int       `json:"gift_id"`
+	Name       string    `json:"name"`
+	Num        int       `json:"num"`
+	Model      string    `json:"model"`
+	Backdrop   string    `json:"backdrop"`
+	Symbol     string    `json:"symbol"`
+	Bid        float64   `json:"bid"`
+	EndsAt     time.Time `json:"ends_at"`
+	BuyURL     string    `json:"buy_url"`
+	Strategies []string  `json:"strategies"` // that sent an alert
+}
+
+// auctions the scanner has seen and that have not ended, soonest ending first
+func (a *API) auctions(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	byID := map[string]*auction{}
+	for _, row := range a.opt.Tracker.Rows(now) {
+		if row.Kind != scanner.KindAuction || row.EndsAt.Before(now) {
+			continue
+		}
+		au, ok := byID[row.ID]
+		if !ok {
+			au = &auction{
+				GiftID:     row.GiftID,
+				Name:       row.Name,
+				Num:        row.Num,
+				Model:      row.Model,
+				Backdrop:   row.Backdrop,
+				Symbol:     row.Symbol,
+				Bid:        row.Price,
+				EndsAt:     row.EndsAt,
+				BuyURL:     row.BuyURL,
+				Strategies: []string{},
+			}
+			byID[row.ID] = au
+		}
+		if row.Reason == "" {
+			au.Strategies = append(au.Strategies, row.Strategy)
+		}
+	}
+
+	list := make([]auction, 0, len(byID))
+	for _, au := range byID {
+		sort.Strings(au.Strategies)
+		list = append(list, *au)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].EndsAt.Before(list[j].EndsAt) })
+	writeJSON(w, http.StatusOK, list)
+}
+
+type floorQuote struct {
+	Source string         `json:"source"`
+	Quote  *pricing.Quote `json:"quote,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// quotes ?name= with optional ?model= and ?backdrop= against the floor the
+// scanner compares with, source "floor", and the other markets
+func (a *API) floors(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	key := pricing.GiftKey{Name: q.Get("name"), Model: q.Get("model"), Backdrop: q.Get("backdrop")}
+	if key.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	rules := a.opt.Engine.Rules()
+	sources := map[string]pricing.PriceSource{}
+	if rules.Floor != nil {
+		sources["floor"] = rules.Floor
+	}
+	for name, src := range rules.Others {
+		sources[name] = src
+	}
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]floorQuote, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			list[i].Source = name
+			quote, err := sources[name].Floor(r.Context(), key)
+			if err != nil {
+				list[i].Error = err.Error()
+				return
+			}
+			list[i].Quote = &quote
+		}()
+	}
+	wg.Wait()
+	writeJSON(w, http.StatusOK, list)
+}
+
+type proxyStatus struct {
+	Proxy     string `json:"proxy"`
+	IP        string `json:"ip,omitempty"`
+	LatencyMS int64  `json:"latency_ms,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// checks every proxy through ipify, like the scanner does at startup
+func (a *API) proxies(w http.ResponseWriter, r *http.Request) {
+	proxies := []*url.URL{}
+	seen := map[string]bool{}
+	for _, p := range a.opt.Proxies {
+		if !seen[p.String()] {
+			seen[p.String()] = true
+			proxies = append(proxies, p)
+		}
+	}
+	if len(proxies) == 0 {
+		proxies = append(proxies, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), proxyTimeout)
+	defer cancel()
+	list := make([]proxyStatus, len(proxies))
+	var wg sync.WaitGroup
+	for i, p := range proxies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			list[i].Proxy = "direct"
+			if p != nil {
+				list[i].Proxy = p.Redacted()
+			}
+			start := time.Now()
+			addr, err := a.opt.CheckProxy(ctx, p)
+			if err != nil {
+				msg := err.Error()
+				if p != nil && p.User != nil {
+					// dial errors may repeat the proxy url with its credentials
+					msg = strings.ReplaceAll(msg, p.User.String(), "xxxxx")
+				}
+				list[i].Error = msg
+				return
+			}
+			list[i].IP = addr
+			list[i].LatencyMS = time.Since(start).Milliseconds()
+		}()
+	}
+	wg.Wait()
+	writeJSON(w, http.StatusOK, list)
+}
+
+// alerts sent within ?since=, a duration such as 30m, 24h by default
+func (a *API) alerts(w http.ResponseWriter, r *http.Request) {
+	if a.opt.Alerts == nil {
+		writeError(w, http.StatusNotFound, "alerts are only kept with db_path")
+		return
+	}
+	window := alertsWindow
+	if s := r.URL.Query().Get("since"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("since: %q is not a positive duration", s))
+			return
+		}
+		window = d
+	}
+	alerts, err := a.opt.Alerts.Alerts(time.Now().Add(-window), time.Time{})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, alerts)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
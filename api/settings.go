@@ -0,0 +1,135 @@
+package api
+
+import (
+	"autobid/config"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+)
+
+const maxBody = 1 << 20
+
+// top level keys PATCH /api/thresholds may change
+var thresholdKeys = []string{
+	"min_profit",
+	"min_profit_ton",
+	"min_bids",
+	"max_bids",
+	"max_bid",
+	"min_auction_end",
+	"max_auction_end",
+	"min_near_floor",
+	"min_recent_sales",
+	"max_model_rarity",
+	"max_combined_rarity",
+}
+
+// thresholds decoded into integers, fractions would be truncated
+var integerKeys = []string{"min_bids", "max_bids", "min_near_floor", "min_recent_sales"}
+
+type watchlist struct {
+	Allow []config.Match `json:"allow"`
+	Deny  []config.Match `json:"deny"`
+}
+
+type settingsResponse struct {
+	Changes []string `json:"changes"`
+	Values  any      `json:"values"`
+}
+
+func (a *API) thresholds(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.opt.Settings.Current().Values(thresholdKeys...))
+}
+
+// sets the keys of a JSON object, others keep their value
+func (a *API) setThresholds(w http.ResponseWriter, r *http.Request) {
+	values := map[string]any{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody)).Decode(&values); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid body: %v", err))
+		return
+	}
+	for key, v := range values {
+		if !slices.Contains(thresholdKeys, key) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("%s is not a threshold", key))
+			return
+		}
+		if err := checkThreshold(v, slices.Contains(integerKeys, key)); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("%s: %v", key, err))
+			return
+		}
+	}
+	a.set(w, values, func(cfg *config.Config) any { return cfg.Values(thresholdKeys...) })
+}
+
+// JSON numbers arrive as float64, negative counts would wrap around and
+// fractions be cut off when decoded
+func checkThreshold(v any, integer bool) error {
+	n, ok := v.(float64)
+	switch {
+	case !ok:
+		return fmt.Errorf("must be a number, got %v", v)
+	case n < 0:
+		return fmt.Errorf("must not be negative, got %v", n)
+	case integer && n != math.Trunc(n):
+		return fmt.Errorf("must be a whole number, got %v", n)
+	case integer && n > math.MaxInt32:
+		return fmt.Errorf("must be at most %d, got %v", math.MaxInt32, n)
+	}
+	return nil
+}
+
+func (a *API) watchlist(w http.ResponseWriter, r *http.Request) {
+	cfg := a.opt.Settings.Current()
+	writeJSON(w, http.StatusOK, watchlist{Allow: nonNil(cfg.Allow), Deny: nonNil(cfg.Deny)})
+}
+
+// replaces the allow and deny lists that are in the body
+func (a *API) setWatchlist(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Allow *[]config.Match `json:"allow"`
+		Deny  *[]config.Match `json:"deny"`
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid body: %v", err))
+		return
+	}
+	values := map[string]any{}
+	if body.Allow != nil {
+		values["allow"] = nonNil(*body.Allow)
+	}
+	if body.Deny != nil {
+		values["deny"] = nonNil(*body.Deny)
+	}
+	a.set(w, values, func(cfg *config.Config) any {
+		return watchlist{Allow: nonNil(cfg.Allow), Deny: nonNil(cfg.Deny)}
+	})
+}
+
+// applies values like a config edit and answers with the changes and the
+// updated values, invalid values are rejected with every problem
+func (a *API) set(w http.ResponseWriter, values map[string]any, current func(cfg *config.Config) any) {
+	changes, err := a.opt.Settings.Set(values)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	resp := settingsResponse{Changes: []string{}, Values: current(a.opt.Settings.Current())}
+	for _, c := range changes {
+		resp.Changes = append(resp.Changes, c.String())
+	}
+	sort.Strings(resp.Changes)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// lists are shown as [] rather than null
+func nonNil(list []config.Match) []config.Match {
+	if list == nil {
+		return []config.Match{}
+	}
+	return list
+}
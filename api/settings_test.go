@@ -0,0 +1,82 @@
+package api
+
+import (
+	"autobid/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeSettings struct {
+	cfg config.Config
+	set map[string]any
+}
+
+func (s *fakeSettings) Current() *config.Config { return &s.cfg }
+
+func (s *fakeSettings) Set(values map[string]any) ([]config.Change, error) {
+	s.set = values
+	return nil, nil
+}
+
+func TestSetThresholds(t *testing.T) {
+	tests := []struct {
+		body   string
+		status int
+		error  string // part of the response
+	}{
+		{`{"min_profit": 0.2, "min_bids": 3}`, http.StatusOK, ""},
+		{`{"max_bid": 12.5}`, http.StatusOK, ""},
+		{`{"min_bids": 2.5}`, http.StatusBadRequest, "min_bids: must be a whole number"},
+		{`{"min_recent_sales": 0.1}`, http.StatusBadRequest, "min_recent_sales: must be a whole number"},
+		{`{"min_bids": -1}`, http.StatusBadRequest, "min_bids: must not be negative"},
+		{`{"min_profit": -0.1}`, http.StatusBadRequest, "min_profit: must not be negative"},
+		{`{"max_bids": 1e12}`, http.StatusBadRequest, "max_bids: must be at most"},
+		{`{"min_bids": "3"}`, http.StatusBadRequest, "min_bids: must be a number"},
+		{`{"min_bids": null}`, http.StatusBadRequest, "min_bids: must be a number"},
+		{`{"token": "x"}`, http.StatusBadRequest, "token is not a threshold"},
+		{`[1]`, http.StatusBadRequest, "invalid body"},
+	}
+	for _, tt := range tests {
+		settings := &fakeSettings{}
+		a, err := New(&Options{Token: "secret", Settings: settings})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest(http.MethodPatch, "/api/thresholds", strings.NewReader(tt.body))
+		r.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+
+		if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.error) {
+			t.Errorf("%s: status %d %s, want %d containing %q", tt.body, w.Code, w.Body, tt.status, tt.error)
+		}
+		if tt.status != http.StatusOK && settings.set != nil {
+			t.Errorf("%s: rejected values were set: %v", tt.body, settings.set)
+		}
+	}
+}
+
+func TestAuth(t *testing.T) {
+	a, err := New(&Options{Token: "secret", Settings: &fakeSettings{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for header, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"secret":        http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Bearer secret": http.StatusOK,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/api/thresholds", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("Authorization %q: status %d, want %d", header, w.Code, want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"autobid/logging"
+	"autobid/scanner"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+type auctionRow struct {
+	GiftID   int       `json:"gift_id"`
+	Name     string    `json:"name"`
+	Num      int       `json:"num"`
+	Model    string    `json:"model"`
+	Backdrop string    `json:"backdrop"`
+	Symbol   string    `json:"symbol"`
+	Bids     int       `json:"bids"`
+	MinBid   float64   `json:"min_bid"`
+	Asset    string    `json:"asset"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+// prints the active auctions the scanner would fetch, soonest ending first
+func runAuctions(args []string) {
+	fs := flag.NewFlagSet("auctions", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	cfg, _ := mustLoadConfig(fs, args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	source := &scanner.TonnelAuctions{Proxies: mustParseProxies(cfg), Offset: cfg.GiftsOffset, Limit: cfg.GiftsPerFetch}
+	defer source.Close()
+	gifts, err := source.Auctions(ctx)
+	if err != nil {
+		logging.Fatal("fetching auctions failed", "err", err)
+	}
+
+	rows := []auctionRow{}
+	for _, g := range gifts {
+		if g.Auction == nil {
+			continue
+		}
+		rows = append(rows, auctionRow{
+			GiftID:   g.GiftID,
+			Name:     g.Name,
+			Num:      g.GiftNum,
+			Model:    g.Model,
+			Backdrop: g.Backdrop,
+			Symbol:   g.Symbol,
+			Bids:     len(g.Auction.BidHistory),
+			MinBid:   g.MinBid(),
+			Asset:    g.Asset,
+			EndsAt:   g.Auction.AuctionEndTime,
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].EndsAt.Before(rows[j].EndsAt) })
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(rows)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "gift_id\tgift\tmodel\tbackdrop\tsymbol\tbids\tmin_bid\tends_in")
+	for _, r := range rows {
+		fmt.Fprintf(w, "%d\t%s #%d\t%s\t%s\t%s\t%d\t%f %s\t%s\n", r.GiftID, r.Name, r.Num, r.Model, r.Backdrop, r.Symbol, r.Bids, r.MinBid, r.Asset, time.Until(r.EndsAt).Round(time.Second))
+	}
+	w.Flush()
+}
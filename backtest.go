@@ -0,0 +1,207 @@
+package main
+
+import (
+	"autobid/backtest"
+	"autobid/config"
+	"autobid/history"
+	"autobid/logging"
+	"autobid/pricing"
+	"autobid/scanner"
+	"autobid/store"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func parseFloats(s string) ([]float64, error) {
+	values := []float64{}
+	for _, part := range strings.Split(s, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// every combination of the comma separated values, defaulting to the
+// config. A swept value replaces the top level one and the ones strategies
+// set, overrides setting it keep theirs and are listed in notes. Strategies,
+// filters and overrides are built from each combination the way the scanner
+// builds them.
+func sweep(cfg *config.Config, minProfit, minProfitTon, minBids, minAuctionEnd string) (params []backtest.Params, notes []string, err error) {
+	configs := []config.Config{*cfg}
+
+	expand := func(flagName, values string, integer bool, set func(c *config.Config, v float64)) error {
+		if values == "" {
+			return nil
+		}
+		vs, err := parseFloats(values)
+		if err != nil {
+			return fmt.Errorf("invalid -%s: %w", flagName, err)
+		}
+		for _, v := range vs {
+			switch {
+			case v < 0:
+				return fmt.Errorf("invalid -%s: must not be negative, got %v", flagName, v)
+			case integer && v != math.Trunc(v):
+				return fmt.Errorf("invalid -%s: must be a whole number, got %v", flagName, v)
+			case integer && v > math.MaxInt32:
+				return fmt.Errorf("invalid -%s: must be at most %d, got %v", flagName, math.MaxInt32, v)
+			}
+		}
+		expanded := []config.Config{}
+		for _, c := range configs {
+			for _, v := range vs {
+				// the strategies are shared with the other copies
+				c.Strategies = slices.Clone(c.Strategies)
+				set(&c, v)
+				expanded = append(expanded, c)
+			}
+		}
+		configs = expanded
+		return nil
+	}
+	// overrides of min_profit and min_profit_ton win over the swept values
+	shadowed := func(flagName string, set func(o config.Override) bool) {
+		for _, o := range cfg.Overrides {
+			if set(o) {
+				notes = append(notes, fmt.Sprintf("%s of the override for %s is kept, -%s does not apply to it", flagName, o.Collection, flagName))
+			}
+		}
+		for _, s := range cfg.Strategies {
+			for _, o := range s.Overrides {
+				if set(o) {
+					notes = append(notes, fmt.Sprintf("%s of strategy %s's override for %s is kept, -%s does not apply to it", flagName, s.Name, o.Collection, flagName))
+				}
+			}
+		}
+	}
+
+	if err := expand("min_profit", minProfit, false, func(c *config.Config, v float64) {
+		c.MinProfit = v
+		for i := range c.Strategies {
+			c.Strategies[i].MinProfit = nil
+		}
+	}); err != nil {
+		return nil, nil, err
+	}
+	if minProfit != "" {
+		shadowed("min_profit", func(o config.Override) bool { return o.MinProfit != nil })
+	}
+	if err := expand("min_profit_ton", minProfitTon, false, func(c *config.Config, v float64) {
+		c.MinProfitTon = v
+		for i := range c.Strategies {
+			c.Strategies[i].MinProfitTon = nil
+		}
+	}); err != nil {
+		return nil, nil, err
+	}
+	if minProfitTon != "" {
+		shadowed("min_profit_ton", func(o config.Override) bool { return o.MinProfitTon != nil })
+	}
+	if err := expand("min_bids", minBids, true, func(c *config.Config, v float64) {
+		c.MinBids = uint32(v)
+		for i := range c.Strategies {
+			c.Strategies[i].MinBids = nil
+		}
+	}); err != nil {
+		return nil, nil, err
+	}
+	if err := expand("min_auction_end", minAuctionEnd, false, func(c *config.Config, v float64) {
+		c.MinAuctionEnd = v
+		for i := range c.Strategies {
+			c.Strategies[i].MinAuctionEnd = nil
+		}
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	params = make([]backtest.Params, len(configs))
+	for i := range configs {
+		strategies, err := scanner.StrategiesFromConfig(&configs[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		params[i] = backtest.Params{Thresholds: scanner.ThresholdsFromConfig(&configs[i]), Strategies: strategies}
+	}
+	return params, notes, nil
+}
+
+func runBacktest(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	minProfit := fs.String("min_profit", "", "comma separated min_profit values to sweep")
+	minProfitTon := fs.String("min_profit_ton", "", "comma separated min_profit_ton values to sweep")
+	minBids := fs.String("min_bids", "", "comma separated min_bids values to sweep")
+	minAuctionEnd := fs.String("min_auction_end", "", "comma separated min_auction_end values (seconds) to sweep")
+	since := fs.Duration("since", 7*24*time.Hour, "replay auctions recorded within this period")
+	maxQuoteAge := fs.Duration("max_quote_age", time.Hour, "oldest recorded floor still used")
+	trades := fs.Bool("trades", false, "print every simulated alert")
+	cfg, _ := mustLoadConfig(fs, args)
+
+	params, notes, err := sweep(cfg, *minProfit, *minProfitTon, *minBids, *minAuctionEnd)
+	if err != nil {
+		logging.Fatal("backtest failed", "err", err)
+	}
+
+	db, err := store.OpenReadOnly(cfg.DBPath)
+	if err != nil {
+		logging.Fatal("opening database failed", "path", cfg.DBPath, "err", err)
+	}
+	defer db.Close()
+
+	sales, err := history.Open(cfg.SalesFile)
+	if err != nil {
+		logging.Fatal("loading sales history failed", "path", cfg.SalesFile, "err", err)
+	}
+
+	// the engine logs every replayed scan and missing floor, the report is on stdout
+	if err := logging.Setup(cfg.LogFormat, "error", cfg.Token, cfg.RdbPassword, cfg.PortalsAuth, cfg.APIToken); err != nil {
+		logging.Fatal("configuration error", "err", err)
+	}
+
+	policy, err := pricing.PolicyByName(cfg.FloorPolicy)
+	if err != nil {
+		logging.Fatal("configuration error", "err", err)
+	}
+	results, err := backtest.Run(&backtest.Options{
+		DB:           db,
+		Sales:        sales,
+		FloorSources: cfg.FloorSources,
+		Policy:       policy,
+		MaxQuoteAge:  *maxQuoteAge,
+		From:         time.Now().Add(-*since),
+	}, params)
+	if err != nil {
+		logging.Fatal("backtest failed", "err", err)
+	}
+
+	for _, note := range notes {
+		fmt.Println("note:", note)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "min_profit\tmin_profit_ton\tmin_bids\tmin_auction_end\tauctions\talerts\twon\tunsettled\thit_rate\trealized_profit")
+	for _, r := range results {
+		fmt.Fprintf(w, "%g\t%g\t%d\t%g\t%d\t%d\t%d\t%d\t%.1f%%\t%f\n", r.Params.MinProfit, r.Params.MinProfitTon, r.Params.MinBids, r.Params.MinAuctionEnd.Seconds(), r.Auctions, r.Alerts, r.Won, r.Unsettled, r.HitRate*100, r.RealizedProfit)
+	}
+	w.Flush()
+
+	if *trades {
+		for _, r := range results {
+			fmt.Printf("\nmin_profit=%g min_profit_ton=%g min_bids=%d min_auction_end=%g\n", r.Params.MinProfit, r.Params.MinProfitTon, r.Params.MinBids, r.Params.MinAuctionEnd.Seconds())
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "strategy\tgift_id\tgift\talert_at\tbid\tfloor\tfinal_bid\texit_floor\toutcome\tprofit")
+			for _, t := range r.Trades {
+				fmt.Fprintf(w, "%s\t%d\t%s #%d\t%s\t%f\t%f\t%f\t%f\t%s\t%f\n", t.Strategy, t.GiftID, t.Name, t.Num, t.AlertAt.Format(time.RFC3339), t.Bid, t.Floor, t.FinalBid, t.ExitFloor, t.Outcome(), t.Profit)
+			}
+			w.Flush()
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package backtest
+
+import (
+	"autobid/history"
+	"autobid/pricing"
+	"autobid/scanner"
+	"autobid/store"
+	"autobid/tonnel"
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Options struct {
+	DB    *store.DB
+	Sales *history.Store // optional, settles auctions that ended unobserved
+	// the recorded quotes of these sources are combined as the live bot does
+	FloorSources []string
+	Policy       pricing.Policy
+	MaxQuoteAge  time.Duration // quotes older than this are not used as floor
+	From         time.Time
+	To           time.Time
+}
+
+// Params is one set of strategies to replay, Thresholds are the swept top
+// level values the results are labeled with
+type Params struct {
+	Thresholds scanner.Thresholds
+	Strategies []scanner.Strategy
+}
+
+type Trade struct {
+	Strategy  string
+	GiftID    int
+	Name      string
+	Num       int
+	AlertAt   time.Time
+	Bid       float64
+	Floor     float64
+	FinalBid  float64
+	ExitFloor float64
+	Settled   bool // a sale was recorded or a higher bid seen
+	Won       bool
+	Profit    float64 // realized, 0 when outbid or unsettled
+}
+
+func (t Trade) Outcome() string {
+	switch {
+	case !t.Settled:
+		return "unsettled"
+	case t.Won:
+		return "won"
+	}
+	return "lost"
+}
+
+type Result struct {
+	Params         scanner.Thresholds
+	Auctions       int
+	Alerts         int
+	Won            int
+	Unsettled      int     // no sale recorded and never outbid
+	HitRate        float64 // won / settled
+	RealizedProfit float64
+	Trades         []Trade
+}
+
+type auction struct {
+	snapshots []store.Snapshot
+	finalBid  float64 // highest bid seen, or the sale price
+	sold      bool
+	end       time.Time
+}
+
+// gifts recorded by one scan
+type scan struct {
+	at    time.Time
+	gifts []tonnel.Gift
+}
+
+type data struct {
+	opt      *Options
+	auctions map[int]*auction
+	scans    []scan                              // oldest first
+	quotes   map[pricing.GiftKey][]pricing.Quote // by the key looked up
+}
+
+// replays the recorded scans through the scanner engine once per parameter set
+func Run(opt *Options, params []Params) ([]Result, error) {
+	d, err := load(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(params))
+	for _, p := range params {
+		trades, err := d.replay(p.Strategies)
+		if err != nil {
+			return nil, err
+		}
+		r := Result{Params: p.Thresholds, Auctions: len(d.auctions), Trades: trades}
+		for _, trade := range trades {
+			r.Alerts++
+			if !trade.Settled {
+				r.Unsettled++
+			}
+			if trade.Won {
+				r.Won++
+				r.RealizedProfit += trade.Profit
+			}
+		}
+		if settled := r.Alerts - r.Unsettled; settled > 0 {
+			r.HitRate = float64(r.Won) / float64(settled)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+func load(opt *Options) (*data, error) {
+	snapshots, err := opt.DB.Snapshots(opt.From, opt.To)
+	if err != nil {
+		return nil, err
+	}
+	quotes, err := opt.DB.QuotesBetween(opt.From.Add(-opt.MaxQuoteAge), time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	d := &data{opt: opt, auctions: map[int]*auction{}, quotes: map[pricing.GiftKey][]pricing.Quote{}}
+	for _, q := range quotes {
+		d.quotes[q.Requested] = append(d.quotes[q.Requested], q.Quote)
+	}
+
+	byTime := map[time.Time][]tonnel.Gift{}
+	for id, snaps := range snapshots {
+		a := &auction{}
+		for _, s := range snaps {
+			if s.Gift.Auction == nil {
+				continue
+			}
+			byTime[s.SeenAt] = append(byTime[s.SeenAt], s.Gift)
+			a.snapshots = append(a.snapshots, s)
+			a.end = s.Gift.Auction.AuctionEndTime
+			a.finalBid = max(a.finalBid, s.Gift.FinalBid())
+		}
+		if len(a.snapshots) == 0 {
+			continue
+		}
+		if opt.Sales != nil {
+			if sale, ok := opt.Sales.AuctionSale(id, a.snapshots[0].SeenAt); ok {
+				a.finalBid = max(a.finalBid, sale.Price)
+				a.sold = true
+			}
+		}
+		d.auctions[id] = a
+	}
+
+	// gifts of one scan are recorded with the same time
+	for at, gifts := range byTime {
+		sort.Slice(gifts, func(i, j int) bool { return gifts[i].GiftID < gifts[j].GiftID })
+		d.scans = append(d.scans, scan{at: at, gifts: gifts})
+	}
+	sort.Slice(d.scans, func(i, j int) bool { return d.scans[i].at.Before(d.scans[j].at) })
+	return d, nil
+}
+
+// latest quote of source for key taken in (at-MaxQuoteAge, at]
+func (d *data) quoteAt(source string, key pricing.GiftKey, at time.Time) (pricing.Quote, bool) {
+	var found pricing.Quote
+	ok := false
+	for _, q := range d.quotes[key] {
+		if !strings.EqualFold(q.Source, source) || q.Time.After(at) || at.Sub(q.Time) > d.opt.MaxQuoteAge {
+			continue
+		}
+		if !ok || q.Time.After(found.Time) {
+			found, ok = q, true
+		}
+	}
+	return found, ok
+}
+
+// earliest quote of source for key taken after at
+func (d *data) quoteAfter(source string, key pricing.GiftKey, at time.Time) (pricing.Quote, bool) {
+	var found pricing.Quote
+	ok := false
+	for _, q := range d.quotes[key] {
+		if !strings.EqualFold(q.Source, source) || !q.Time.After(at) {
+			continue
+		}
+		if !ok || q.Time.Before(found.Time) {
+			found, ok = q, true
+		}
+	}
+	return found, ok
+}
+
+// the floor sources of the live bot with the quote pick finds per source,
+// combined by the same policy
+func (d *data) floor(ctx context.Context, key pricing.GiftKey, pick func(source string, key pricing.GiftKey) (pricing.Quote, bool)) (pricing.Quote, error) {
+	aggregate := &pricing.Aggregate{Sources: map[string]pricing.PriceSource{}, Policy: d.opt.Policy}
+	for _, name := range d.opt.FloorSources {
+		aggregate.Sources[name] = recorded{name: name, pick: pick}
+	}
+	return aggregate.Floor(ctx, key)
+}
+
+// one floor source as recorded
+type recorded struct {
+	name string
+	pick func(source string, key pricing.GiftKey) (pricing.Quote, bool)
+}
+
+func (s recorded) Floor(ctx context.Context, key pricing.GiftKey) (pricing.Quote, error) {
+	q, ok := s.pick(s.name, key)
+	if !ok {
+		return pricing.Quote{}, &pricing.NoFloorError{Source: s.name, Key: key}
+	}
+	return q, nil
+}
+
+// feeds the recorded scans to an engine with the given strategies, the first
+// alert of a strategy for an auction is its trade. It wins when the recorded
+// sale is below it and is lost once someone bid more.
+func (d *data) replay(strategies []scanner.Strategy) ([]Trade, error) {
+	r := &replayer{data: d, trades: map[string]bool{}}
+	engine, err := scanner.New(&scanner.Options{
+		Rules:       scanner.Rules{Floor: r, Strategies: strategies},
+		Mode:        scanner.KindAuction,
+		Auctions:    r,
+		Notifier:    r,
+		Clock:       r,
+		Concurrency: 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range d.scans {
+		r.scan = s
+		if _, err := engine.ScanAuctions(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	return r.result, nil
+}
+
+// the recorded world as the engine sees it during a replay: the auctions and
+// time of the current scan and the floors recorded before it
+type replayer struct {
+	*data
+	scan   scan
+	trades map[string]bool // strategy and gift with a trade
+	result []Trade
+}
+
+func (r *replayer) Auctions(ctx context.Context) ([]tonnel.Gift, error) {
+	return r.scan.gifts, nil
+}
+
+func (r *replayer) Floor(ctx context.Context, key pricing.GiftKey) (pricing.Quote, error) {
+	return r.floor(ctx, key, func(source string, key pricing.GiftKey) (pricing.Quote, bool) {
+		return r.quoteAt(source, key, r.scan.at)
+	})
+}
+
+func (r *replayer) Now() time.Time { return r.scan.at }
+
+// never waited on, the engine only waits in Run
+func (r *replayer) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- r.scan.at.Add(d)
+	return ch
+}
+
+func (r *replayer) Notify(ctx context.Context, o scanner.Opportunity) error {
+	key := o.Strategy + ":" + o.ID
+	if r.trades[key] {
+		return nil
+	}
+	r.trades[key] = true
+
+	a := r.auctions[o.GiftID]
+	trade := Trade{
+		Strategy:  o.Strategy,
+		GiftID:    o.GiftID,
+		Name:      o.Name,
+		Num:       o.Num,
+		AlertAt:   r.scan.at,
+		Bid:       o.Price,
+		Floor:     o.Quote.Price,
+		FinalBid:  a.finalBid,
+		ExitFloor: o.Quote.Price,
+		// without a sale the auction is only decided once someone bid more
+		Settled: a.sold || a.finalBid >= o.Price,
+		Won:     a.sold && a.finalBid < o.Price,
+	}
+	exit, err := r.floor(ctx, o.Quote.Key, func(source string, key pricing.GiftKey) (pricing.Quote, bool) {
+		return r.quoteAfter(source, key, a.end)
+	})
+	if err == nil {
+		trade.ExitFloor = exit.Price
+	}
+	if trade.Won {
+		trade.Profit = trade.ExitFloor - trade.Bid
+	}
+	r.result = append(r.result, trade)
+	return nil
+}
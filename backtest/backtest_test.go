@@ -0,0 +1,119 @@
+package backtest
+
+import (
+	"autobid/config"
+	"autobid/history"
+	"autobid/pricing"
+	"autobid/scanner"
+	"autobid/store"
+	"autobid/tonnel"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func auctionGift(id int, name string, end time.Time, bids ...float64) tonnel.Gift {
+	a := &tonnel.Auction{GiftID: id, StartingBid: 10, AuctionEndTime: end}
+	for _, b := range bids {
+		a.BidHistory = append(a.BidHistory, tonnel.BidHistoryEntry{Amount: b})
+	}
+	return tonnel.Gift{GiftID: id, GiftNum: id, Name: name, Model: "Gold", Auction: a}
+}
+
+// filters and overrides of the live scanner apply to the replay
+func TestRunUsesScannerRules(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	start := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	end := start.Add(30 * time.Minute)
+	for _, q := range []pricing.Quote{
+		// fell back to the collection floor
+		{Source: pricing.SourceTonnel, Key: pricing.GiftKey{Name: "Cat"}, Price: 15, Time: start.Add(-time.Minute)},
+		{Source: pricing.SourceTonnel, Key: pricing.GiftKey{Name: "Dog", Model: "Gold"}, Price: 15, Time: start.Add(-time.Minute)},
+		{Source: pricing.SourceTonnel, Key: pricing.GiftKey{Name: "Plush", Model: "Gold"}, Price: 11, Time: start.Add(-time.Minute)},
+		{Source: pricing.SourcePortals, Key: pricing.GiftKey{Name: "Plush", Model: "Gold"}, Price: 12, Time: start.Add(-time.Minute)},
+		{Source: pricing.SourceTonnel, Key: pricing.GiftKey{Name: "Plush", Model: "Gold"}, Price: 13, Time: end.Add(time.Minute)},
+		{Source: pricing.SourceTonnel, Key: pricing.GiftKey{Name: "Frog", Model: "Gold"}, Price: 15, Time: start.Add(-time.Minute)},
+	} {
+		requested := pricing.GiftKey{Name: q.Key.Name, Model: "Gold"}
+		if err := db.RecordQuote(requested, q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	scans := []struct {
+		at    time.Time
+		gifts []tonnel.Gift
+	}{
+		{start, []tonnel.Gift{auctionGift(1, "Cat", end), auctionGift(2, "Dog", end), auctionGift(3, "Plush", end), auctionGift(4, "Frog", end, 9)}},
+		{start.Add(10 * time.Minute), []tonnel.Gift{auctionGift(1, "Cat", end), auctionGift(2, "Dog", end), auctionGift(3, "Plush", end)}},
+		{end.Add(10 * time.Minute), []tonnel.Gift{auctionGift(1, "Cat", end, 12), auctionGift(3, "Plush", end)}},
+	}
+	for _, s := range scans {
+		if err := db.RecordGifts(s.gifts, s.at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Frog sold below the alerted bid, Plush has no recorded sale
+	sales, err := history.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sales.Add([]history.Sale{{GiftID: 4, Name: "Frog", Price: 9, Auction: true, SoldAt: end}}); err != nil {
+		t.Fatal(err)
+	}
+
+	minProfit := 0.05
+	cfg := &config.Config{
+		MinProfit: 0.1,
+		Deny:      []config.Match{{Collection: "Dog"}},
+		Overrides: []config.Override{{Collection: "Plush", MinProfit: &minProfit}},
+	}
+	strategies, err := scanner.StrategiesFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// floors are combined like the live bot's floor_sources and floor_policy
+	opt := &Options{
+		DB:           db,
+		Sales:        sales,
+		FloorSources: []string{"tonnel", "portals"},
+		Policy:       pricing.Median,
+		MaxQuoteAge:  time.Hour,
+		From:         start.Add(-time.Hour),
+	}
+	results, err := Run(opt, []Params{{Strategies: strategies}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := results[0]
+	if r.Auctions != 4 || r.Alerts != 3 || r.Won != 1 || r.Unsettled != 1 || r.HitRate != 0.5 {
+		t.Fatalf("auctions %d, alerts %d, won %d, unsettled %d, hit rate %v, want 4, 3, 1, 1, 0.5: %+v", r.Auctions, r.Alerts, r.Won, r.Unsettled, r.HitRate, r.Trades)
+	}
+	frog := auctionGift(4, "Frog", end, 9)
+	frogBid := frog.MinBid()
+	want := map[int]Trade{
+		1: {Strategy: config.DefaultStrategy, GiftID: 1, Name: "Cat", Num: 1, AlertAt: start, Bid: 10, Floor: 15, FinalBid: 12, ExitFloor: 15, Settled: true},
+		3: {Strategy: config.DefaultStrategy, GiftID: 3, Name: "Plush", Num: 3, AlertAt: start, Bid: 10, Floor: 11.5, ExitFloor: 13},
+		4: {Strategy: config.DefaultStrategy, GiftID: 4, Name: "Frog", Num: 4, AlertAt: start, Bid: frogBid, Floor: 15, FinalBid: 9, ExitFloor: 15, Settled: true, Won: true, Profit: 15 - frogBid},
+	}
+	for _, trade := range r.Trades {
+		w, ok := want[trade.GiftID]
+		if !ok {
+			t.Errorf("unexpected trade %+v", trade)
+			continue
+		}
+		if !trade.AlertAt.Equal(w.AlertAt) {
+			t.Errorf("gift %d alerted at %s, want %s", trade.GiftID, trade.AlertAt, w.AlertAt)
+		}
+		trade.AlertAt = w.AlertAt
+		if trade != w {
+			t.Errorf("trade %+v, want %+v", trade, w)
+		}
+	}
+}
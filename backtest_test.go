@@ -0,0 +1,52 @@
+package main
+
+import (
+	"autobid/config"
+	"strings"
+	"testing"
+)
+
+func TestSweep(t *testing.T) {
+	own, kept := 0.3, 0.01
+	cfg := &config.Config{
+		MinProfit: 0.1,
+		Overrides: []config.Override{{Collection: "Plush", MinProfit: &kept}},
+		Strategies: []config.Strategy{
+			{Name: "own", MinProfit: &own},
+			{Name: "inherited"},
+		},
+	}
+	params, notes, err := sweep(cfg, "0.05,0.2", "", "0,2", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(params) != 4 {
+		t.Fatalf("%d parameter sets, want 4", len(params))
+	}
+	for _, p := range params {
+		for _, s := range p.Strategies {
+			if s.Thresholds.MinProfit != p.Thresholds.MinProfit || s.Thresholds.MinBids != p.Thresholds.MinBids {
+				t.Errorf("strategy %s: %+v, want the swept %+v", s.Name, s.Thresholds, p.Thresholds)
+			}
+		}
+	}
+	if cfg.Strategies[0].MinProfit != &own {
+		t.Error("the config's strategies were changed")
+	}
+	if len(notes) != 1 || !strings.Contains(notes[0], "override for Plush") {
+		t.Errorf("notes %q, want the Plush override", notes)
+	}
+
+	for _, bad := range []struct{ minProfit, minBids, want string }{
+		{"", "1.5", "must be a whole number"},
+		{"", "-1", "must not be negative"},
+		{"", "1e12", "must be at most"},
+		{"-0.1", "", "must not be negative"},
+		{"x", "", "invalid -min_profit"},
+	} {
+		_, _, err := sweep(cfg, bad.minProfit, "", bad.minBids, "")
+		if err == nil || !strings.Contains(err.Error(), bad.want) {
+			t.Errorf("min_profit %q, min_bids %q: error %v, want %q", bad.minProfit, bad.minBids, err, bad.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"autobid/metrics"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// bounds a refresh, which runs detached from the ctx of the callers sharing it
+const refreshTimeout = 30 * time.Second
+
+type Store interface {
+	// ok is false on a miss
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+type entry struct {
+	Value    []byte    `json:"value"`
+	StoredAt time.Time `json:"stored_at"`
+}
+
+type Cache struct {
+	store Store
+	group singleflight.Group
+}
+
+func New(store Store) *Cache {
+	return &Cache{store: store}
+}
+
+// Fetch returns the value cached under key if it is younger than ttl. Values
+// younger than ttl+stale are returned as is and refreshed in the background.
+// Concurrent fetches of the same key share one call to fn.
+func (c *Cache) Fetch(ctx context.Context, key string, ttl, stale time.Duration, fn func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	raw, ok, err := c.store.Get(ctx, key)
+	if err != nil {
+		slog.Warn("cache get failed", "key", key, "err", err)
+	}
+	if ok {
+		var e entry
+		if err := json.Unmarshal(raw, &e); err == nil {
+			age := time.Since(e.StoredAt)
+			if age < ttl {
+				metrics.CacheRequests.WithLabelValues("hit").Inc()
+				return e.Value, nil
+			}
+			if age < ttl+stale {
+				metrics.CacheRequests.WithLabelValues("stale").Inc()
+				go func() {
+					if _, err := c.refresh(context.WithoutCancel(ctx), key, ttl+stale, fn); err != nil {
+						slog.Warn("cache refresh failed", "key", key, "err", err)
+					}
+				}()
+				return e.Value, nil
+			}
+		}
+	}
+
+	metrics.CacheRequests.WithLabelValues("miss").Inc()
+	return c.refresh(ctx, key, ttl+stale, fn)
+}
+
+func (c *Cache) refresh(ctx context.Context, key string, keep time.Duration, fn func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	ch := c.group.DoChan(key, func() (interface{}, error) {
+		// the first caller leaving must not fail the others
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refreshTimeout)
+		defer cancel()
+
+		value, err := fn(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		raw, err := json.Marshal(entry{Value: value, StoredAt: time.Now()})
+		if err != nil {
+			return nil, err
+		}
+		if err := c.store.Set(ctx, key, raw, keep); err != nil {
+			slog.Warn("cache set failed", "key", key, "err", err)
+		}
+		return value, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-ch:
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		return r.Val.([]byte), nil
+	}
+}
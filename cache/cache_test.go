@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// the caller that started a fetch leaving does not fail the others waiting on it
+func TestFetchSharedAfterCancel(t *testing.T) {
+	c := New(NewLRU(10))
+	started, release := make(chan struct{}), make(chan struct{})
+	fn := func(ctx context.Context) ([]byte, error) {
+		close(started)
+		select {
+		case <-release:
+			return []byte("floor"), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := c.Fetch(first, "k", time.Minute, 0, fn)
+		firstErr <- err
+	}()
+	<-started
+
+	second := make(chan []byte, 1)
+	go func() {
+		value, err := c.Fetch(context.Background(), "k", time.Minute, 0, fn)
+		if err != nil {
+			t.Error(err)
+		}
+		second <- value
+	}()
+
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("first fetch: %v, want canceled", err)
+	}
+	close(release)
+	if value := <-second; string(value) != "floor" {
+		t.Errorf("second fetch %q, want floor", value)
+	}
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type lruItem struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+type LRU struct {
+	size  int
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+}
+
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:  size,
+		order: list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+func (c *LRU) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	item := el.Value.(*lruItem)
+	if time.Now().After(item.expires) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(el)
+	return item.value, true, nil
+}
+
+func (c *LRU) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value = &lruItem{key: key, value: value, expires: time.Now().Add(ttl)}
+		c.order.MoveToFront(el)
+		return nil
+	}
+
+	c.items[key] = c.order.PushFront(&lruItem{key: key, value: value, expires: time.Now().Add(ttl)})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruItem).key)
+	}
+	return nil
+}
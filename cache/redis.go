@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type Redis struct {
+	rdb    *redis.Client
+	prefix string
+}
+
+func NewRedis(rdb *redis.Client, prefix string) *Redis {
+	return &Redis{rdb: rdb, prefix: prefix}
+}
+
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	raw, err := c.rdb.Get(ctx, c.prefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return raw, true, nil
+}
+
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.rdb.Set(ctx, c.prefix+key, value, ttl).Err()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"autobid/config"
+	"autobid/logging"
+	"flag"
+	"net/url"
+)
+
+// flags every subcommand shares: where config.json is and an override per config key
+type configFlags struct {
+	dir       *string
+	overrides config.Overrides
+}
+
+func newConfigFlags(fs *flag.FlagSet) *configFlags {
+	c := &configFlags{
+		dir:       fs.String("dir", ".", "directory holding config.json"),
+		overrides: config.Overrides{},
+	}
+	c.overrides.Register(fs)
+	return c
+}
+
+func (c *configFlags) load() (*config.Config, error) {
+	c.overrides.Apply()
+	return config.LoadConfig(*c.dir)
+}
+
+// parses args, loads the config and sets up logging, exits on errors. Flags
+// may follow the returned positional arguments.
+func mustLoadConfig(fs *flag.FlagSet, args []string) (*config.Config, []string) {
+	flags := newConfigFlags(fs)
+	positional := parseInterspersed(fs, args)
+
+	cfg, err := flags.load()
+	if err != nil {
+		logging.Fatal("configuration error", "err", err)
+	}
+	if err := logging.Setup(cfg.LogFormat, cfg.LogLevel, cfg.Token, cfg.RdbPassword, cfg.PortalsAuth, cfg.APIToken); err != nil {
+		logging.Fatal("configuration error", "err", err)
+	}
+	return cfg, positional
+}
+
+// flag stops at the first positional argument, this keeps parsing after it
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	positional := []string{}
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func mustParseProxies(cfg *config.Config) []*url.URL {
+	proxies := []*url.URL{}
+	for _, proxyStr := range cfg.Proxies {
+		proxy, err := url.Parse(proxyStr)
+		if err != nil {
+			logging.Fatal("invalid proxy address", "err", err)
+		}
+		proxies = append(proxies, proxy)
+	}
+	return proxies
+}
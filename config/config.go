@@ -1,89 +1,259 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"log"
-	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	GiftsOffset        uint32   `mapstructure:"gifts_offset"`
-	GiftsPerFetch      uint32   `mapstructure:"gifts_per_fetch"`
-	ConcurrentRequests int      `mapstructure:"concurrent_requests"`
-	MinProfit          float64  `mapstructure:"min_profit"`
-	MinProfitTon       float64  `mapstructure:"min_profit_ton"`
-	RareBackdrops      []string `mapstructure:"rare_backdrops"`
-	MinBids            uint32   `mapstructure:"min_bids"`
-	MinAuctionEnd      float64  `mapstructure:"min_auction_end"`
-	Expiration         float64  `mapstructure:"expiration"`
+	GiftsOffset        uint32             `mapstructure:"gifts_offset"`
+	GiftsPerFetch      uint32             `mapstructure:"gifts_per_fetch"`
+	ConcurrentRequests int                `mapstructure:"concurrent_requests"`
+	MinProfit          float64            `mapstructure:"min_profit"`
+	MinProfitTon       float64            `mapstructure:"min_profit_ton"`
+	RareBackdrops      []string           `mapstructure:"rare_backdrops"`
+	RareTraits         []RareTrait        `mapstructure:"rare_traits"`
+	MaxModelRarity     float64            `mapstructure:"max_model_rarity"`
+	MaxCombinedRarity  float64            `mapstructure:"max_combined_rarity"`
+	MinBids            uint32             `mapstructure:"min_bids"`
+	MaxBids            uint32             `mapstructure:"max_bids"`
+	MinAuctionEnd      float64            `mapstructure:"min_auction_end"`
+	MaxAuctionEnd      float64            `mapstructure:"max_auction_end"`
+	MaxBid             float64            `mapstructure:"max_bid"`
+	Allow              []Match            `mapstructure:"allow"`
+	Deny               []Match            `mapstructure:"deny"`
+	Overrides          []Override         `mapstructure:"overrides"`
+	Expiration         float64            `mapstructure:"expiration"`
+	Mode               string             `mapstructure:"mode"`
+	ScanInterval       float64            `mapstructure:"scan_interval"`
+	TonnelFee          float64            `mapstructure:"tonnel_fee"`
+	PortalsFee         float64            `mapstructure:"portals_fee"`
+	FloorSources       []string           `mapstructure:"floor_sources"`
+	FloorPolicy        string             `mapstructure:"floor_policy"`
+	FloorEstimator     string             `mapstructure:"floor_estimator"`
+	LiquidityBand      float64            `mapstructure:"liquidity_band"`
+	SalesWindow        float64            `mapstructure:"sales_window"`
+	RecentSalesTTL     float64            `mapstructure:"recent_sales_ttl"`
+	MinNearFloor       int                `mapstructure:"min_near_floor"`
+	MinRecentSales     int                `mapstructure:"min_recent_sales"`
+	SalesFile          string             `mapstructure:"sales_file"`
+	SalesInterval      float64            `mapstructure:"sales_interval"`
+	SalesPerFetch      uint32             `mapstructure:"sales_per_fetch"`
+	FairValueWindow    float64            `mapstructure:"fair_value_window"`
+	FairValueMinSales  int                `mapstructure:"fair_value_min_sales"`
+	DBPath             string             `mapstructure:"db_path"`
+	Retention          float64            `mapstructure:"retention"`
+	PruneInterval      float64            `mapstructure:"prune_interval"`
+	PaperTrading       bool               `mapstructure:"paper_trading"`
+	PaperInterval      float64            `mapstructure:"paper_interval"`
+	PaperSettleAfter   float64            `mapstructure:"paper_settle_after"`
+	MinPollInterval    float64            `mapstructure:"min_poll_interval"`
+	MaxPollInterval    float64            `mapstructure:"max_poll_interval"`
+	RetryBackoff       float64            `mapstructure:"retry_backoff"`
+	MaxRetryBackoff    float64            `mapstructure:"max_retry_backoff"`
+	ErrorAlertAfter    int                `mapstructure:"error_alert_after"`
+	ShutdownTimeout    float64            `mapstructure:"shutdown_timeout"`
+	ListenAddr         string             `mapstructure:"listen_addr"`
+	Dashboard          bool               `mapstructure:"dashboard"`
+	APIToken           string             `mapstructure:"api_token"`
+	MaxScanAge         float64            `mapstructure:"max_scan_age"`
+	LogFormat          string             `mapstructure:"log_format"`
+	LogLevel           string             `mapstructure:"log_level"`
+	TraceExporter      string             `mapstructure:"trace_exporter"`
+	TraceEndpoint      string             `mapstructure:"trace_endpoint"`
+	TraceInsecure      bool               `mapstructure:"trace_insecure"`
+	TraceSampleRatio   float64            `mapstructure:"trace_sample_ratio"`
+	CacheSize          int                `mapstructure:"cache_size"`
+	CacheTTLs          map[string]float64 `mapstructure:"cache_ttl"`
+	CacheStale         float64            `mapstructure:"cache_stale"`
+	Strategies         []Strategy         `mapstructure:"strategies"`
 
 	RdbAddr     string   `mapstructure:"redis_addr"`
 	RdbPassword string   `mapstructure:"redis_password"`
 	Proxies     []string `mapstructure:"proxies"`
 	Token       string   `mapstructure:"token"`
 	ChatID      int64    `mapstructure:"chat_id"`
+	AdminChatID int64    `mapstructure:"admin_chat_id"`
+	PortalsAuth string   `mapstructure:"portals_auth"`
 }
 
+const (
+	ModeAuctions = "auctions"
+	ModeListings = "listings"
+)
+
+// Strategy is a named set of filters, unset thresholds fall back to the top
+// level ones. Top level allow and deny rules apply to every strategy.
+type Strategy struct {
+	Name              string      `mapstructure:"name"`
+	Collections       []string    `mapstructure:"collections"` // patterns, empty allows every collection
+	Exclude           []string    `mapstructure:"exclude"`     // patterns
+	Allow             []Match     `mapstructure:"allow"`
+	Deny              []Match     `mapstructure:"deny"`
+	Overrides         []Override  `mapstructure:"overrides"` // checked before the top level ones
+	MinProfit         *float64    `mapstructure:"min_profit"`
+	MinProfitTon      *float64    `mapstructure:"min_profit_ton"`
+	MaxBid            *float64    `mapstructure:"max_bid"`
+	MinBids           *uint32     `mapstructure:"min_bids"`
+	MaxBids           *uint32     `mapstructure:"max_bids"`
+	MinAuctionEnd     *float64    `mapstructure:"min_auction_end"`
+	MaxAuctionEnd     *float64    `mapstructure:"max_auction_end"`
+	MinNearFloor      *int        `mapstructure:"min_near_floor"`
+	MinRecentSales    *int        `mapstructure:"min_recent_sales"`
+	RareBackdrops     []string    `mapstructure:"rare_backdrops"`
+	RareTraits        []RareTrait `mapstructure:"rare_traits"`
+	MaxModelRarity    *float64    `mapstructure:"max_model_rarity"`
+	MaxCombinedRarity *float64    `mapstructure:"max_combined_rarity"`
+	ChatID            int64       `mapstructure:"chat_id"` // 0 alerts the top level chat
+}
+
+// Match selects gifts by glob or /regexp/ patterns, empty fields match anything
+type Match struct {
+	Collection string `mapstructure:"collection" json:"collection,omitempty"`
+	Model      string `mapstructure:"model" json:"model,omitempty"`
+	Backdrop   string `mapstructure:"backdrop" json:"backdrop,omitempty"`
+}
+
+// RareTrait picks the floor gifts are compared with when their traits match
+// all set patterns and are at most as common as the set percentages. Rare
+// traits are checked before rare backdrops, the first match wins.
+type RareTrait struct {
+	Model             string  `mapstructure:"model"`
+	Backdrop          string  `mapstructure:"backdrop"`
+	Symbol            string  `mapstructure:"symbol"`
+	MaxModelRarity    float64 `mapstructure:"max_model_rarity"` // percent
+	MaxBackdropRarity float64 `mapstructure:"max_backdrop_rarity"`
+	MaxSymbolRarity   float64 `mapstructure:"max_symbol_rarity"`
+	Floor             string  `mapstructure:"floor"` // model, backdrop (default), model_backdrop or collection
+}
+
+// Override replaces thresholds for the collections matching Collection, the
+// first matching override wins
+type Override struct {
+	Collection   string   `mapstructure:"collection"`
+	MinProfit    *float64 `mapstructure:"min_profit"`
+	MinProfitTon *float64 `mapstructure:"min_profit_ton"`
+	MaxBid       *float64 `mapstructure:"max_bid"` // TON
+}
+
+// DefaultStrategy is used when no strategies are configured
+const DefaultStrategy = "default"
+
 func LoadConfig(path string) (*Config, error) {
 	viper.AddConfigPath(path)
 	viper.SetConfigName("config")
 	viper.SetConfigType("json")
 
-	// Set defaults (mirror constants)
-	viper.SetDefault("gifts_offset", 0)
-	viper.SetDefault("gifts_per_fetch", 30)
-	viper.SetDefault("concurrent_requests", 5)
-	viper.SetDefault("min_profit", 0.06)
-	viper.SetDefault("min_profit_ton", 0.0)
-	viper.SetDefault("rare_backdrops", []string{"Black"})
-	viper.SetDefault("min_bids", 0)
-	viper.SetDefault("min_auction_end", 0.0)
-	viper.SetDefault("proxies", []string{})
-	viper.SetDefault("expiration", 60*60) // 1 hour
-
-	// Enable reading from environment variables
-	viper.AutomaticEnv()
-	viper.BindEnv("redis_addr", "REDIS_ADDR")
-	viper.BindEnv("redis_password", "REDIS_PASSWORD")
-	viper.BindEnv("token", "TOKEN")
-	viper.BindEnv("chat_id", "CHAT_ID")
+	setDefaults(viper.GetViper())
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
 
+	return load(viper.GetViper())
+}
+
+// defaults and env bindings of every key, for LoadConfig and reloads
+func setDefaults(v *viper.Viper) {
+	// Set defaults (mirror constants)
+	v.SetDefault("gifts_offset", 0)
+	v.SetDefault("gifts_per_fetch", 30)
+	v.SetDefault("concurrent_requests", 5)
+	v.SetDefault("min_profit", 0.06)
+	v.SetDefault("min_profit_ton", 0.0)
+	v.SetDefault("rare_backdrops", []string{"Black"})
+	v.SetDefault("min_bids", 0)
+	v.SetDefault("min_auction_end", 0.0)
+	v.SetDefault("proxies", []string{})
+	v.SetDefault("expiration", 60*60) // 1 hour
+	v.SetDefault("mode", ModeAuctions)
+	v.SetDefault("scan_interval", 30)
+	v.SetDefault("tonnel_fee", 0.06)
+	v.SetDefault("portals_fee", 0.05)
+	v.SetDefault("floor_sources", []string{"tonnel"})
+	v.SetDefault("floor_policy", "min")
+	v.SetDefault("floor_estimator", "lowest")
+	v.SetDefault("liquidity_band", 0.1)
+	v.SetDefault("sales_window", 24*60*60) // 1 day
+	v.SetDefault("recent_sales_ttl", 30*60)
+	v.SetDefault("min_near_floor", 0)
+	v.SetDefault("min_recent_sales", 0)
+	v.SetDefault("sales_file", "sales.jsonl")
+	v.SetDefault("sales_interval", 5*60)
+	v.SetDefault("sales_per_fetch", 100)
+	v.SetDefault("fair_value_window", 7*24*60*60) // 1 week
+	v.SetDefault("fair_value_min_sales", 3)
+	v.SetDefault("db_path", "autobid.db")
+	v.SetDefault("retention", 30*24*60*60) // 30 days
+	v.SetDefault("prune_interval", 60*60)  // 1 hour
+	v.SetDefault("paper_trading", false)
+	v.SetDefault("paper_interval", 60)
+	v.SetDefault("paper_settle_after", 60*60) // 1 hour
+	v.SetDefault("min_poll_interval", 5)
+	v.SetDefault("max_poll_interval", 10*60)
+	v.SetDefault("retry_backoff", 5)
+	v.SetDefault("max_retry_backoff", 5*60)
+	v.SetDefault("error_alert_after", 5)
+	v.SetDefault("shutdown_timeout", 10)
+	v.SetDefault("listen_addr", "127.0.0.1:8080") // the dashboard and /pnl have no auth
+	v.SetDefault("dashboard", true)
+	v.SetDefault("api_token", "")
+	v.SetDefault("max_scan_age", 30*60)
+	v.SetDefault("log_format", "text")
+	v.SetDefault("log_level", "info")
+	v.SetDefault("trace_exporter", "")
+	v.SetDefault("trace_endpoint", "localhost:4318")
+	v.SetDefault("trace_insecure", true)
+	v.SetDefault("trace_sample_ratio", 1)
+	v.SetDefault("cache_size", 1000)
+	v.SetDefault("cache_ttl.tonnel", 60)
+	v.SetDefault("cache_stale", 60)
+
+	// Enable reading from environment variables
+	v.AutomaticEnv()
+	v.BindEnv("redis_addr", "REDIS_ADDR")
+	v.BindEnv("redis_password", "REDIS_PASSWORD")
+	v.BindEnv("token", "TOKEN")
+	v.BindEnv("chat_id", "CHAT_ID")
+	v.BindEnv("admin_chat_id", "ADMIN_CHAT_ID")
+	v.BindEnv("portals_auth", "PORTALS_AUTH")
+	v.BindEnv("api_token", "API_TOKEN")
+}
+
+// decodes and validates the config v has read, the error lists every problem
+func load(v *viper.Viper) (*Config, error) {
 	var cfg Config
-	if err := viper.Unmarshal(&cfg); err != nil {
+	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
-	if env := os.Getenv("PROXIES"); env != "" {
-		parts := strings.Split(env, ",")
+	if env := os.Getenv("PROXIES"); env != "" && overridden["proxies"] == nil {
 		cfg.Proxies = nil
-		for _, p := range parts {
-			proxyStr := strings.TrimSpace(p)
-			if proxyStr == "" {
-				continue
-			}
-			if _, err := url.Parse(proxyStr); err != nil {
-				return nil, fmt.Errorf("invalid proxy URL in PROXIES env: %s", proxyStr)
+		for _, p := range strings.Split(env, ",") {
+			if proxyStr := strings.TrimSpace(p); proxyStr != "" {
+				cfg.Proxies = append(cfg.Proxies, proxyStr)
 			}
-			cfg.Proxies = append(cfg.Proxies, proxyStr)
 		}
 	}
-
-	log.Printf("Loaded %d proxies\n", len(cfg.Proxies))
-
-	if cfg.Token == "" {
-		return nil, fmt.Errorf("TOKEN env is required")
-	}
-	if cfg.ChatID == 0 {
-		return nil, fmt.Errorf("CHAT_ID env is required and must be a valid integer")
+	if cfg.AdminChatID == 0 {
+		cfg.AdminChatID = cfg.ChatID
 	}
 
+	if err := errors.Join(unknownKeys(v.ConfigFileUsed()), cfg.Validate()); err != nil {
+		return nil, err
+	}
 	return &cfg, nil
 }
+
+// falls back to expiration for sources without their own ttl
+func (c *Config) CacheTTL(source string) time.Duration {
+	if ttl, ok := c.CacheTTLs[strings.ToLower(source)]; ok {
+		return time.Duration(ttl * float64(time.Second))
+	}
+	return time.Duration(c.Expiration * float64(time.Second))
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"flag"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Overrides are config values given on the command line, they win over
+// config.json and the env
+type Overrides map[string]any
+
+// Register adds a flag named after every scalar or string list key, set
+// flags are recorded in o. String lists are comma separated. Keys the flag set
+// already defines keep the command's own meaning.
+func (o Overrides) Register(fs *flag.FlagSet) {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := f.Tag.Get("mapstructure")
+		if key == "" || fs.Lookup(key) != nil {
+			continue
+		}
+		usage := "override " + key
+		switch f.Type.Kind() {
+		case reflect.Bool:
+			fs.BoolFunc(key, usage, func(s string) error {
+				v, err := strconv.ParseBool(s)
+				o[key] = v
+				return err
+			})
+		case reflect.String:
+			fs.Func(key, usage, func(s string) error {
+				o[key] = s
+				return nil
+			})
+		case reflect.Int, reflect.Int64:
+			fs.Func(key, usage, func(s string) error {
+				v, err := strconv.ParseInt(s, 10, 64)
+				o[key] = v
+				return err
+			})
+		case reflect.Uint32:
+			fs.Func(key, usage, func(s string) error {
+				v, err := strconv.ParseUint(s, 10, 32)
+				o[key] = v
+				return err
+			})
+		case reflect.Float64:
+			fs.Func(key, usage, func(s string) error {
+				v, err := strconv.ParseFloat(s, 64)
+				o[key] = v
+				return err
+			})
+		case reflect.Slice:
+			if f.Type.Elem().Kind() != reflect.String {
+				continue
+			}
+			fs.Func(key, usage+" (comma separated)", func(s string) error {
+				list := []string{}
+				for _, v := range strings.Split(s, ",") {
+					if v = strings.TrimSpace(v); v != "" {
+						list = append(list, v)
+					}
+				}
+				o[key] = list
+				return nil
+			})
+		}
+	}
+}
+
+// values set on the command line, env values like PROXIES do not replace them.
+// Only written before the config is loaded.
+var overridden = Overrides{}
+
+// Apply makes the overrides take effect for LoadConfig and later reloads
+func (o Overrides) Apply() {
+	for key, v := range o {
+		viper.Set(key, v)
+		overridden[key] = v
+	}
+}
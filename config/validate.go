@@ -0,0 +1,401 @@
+package config
+
+import (
+	"autobid/match"
+	"autobid/pricing"
+	"autobid/rarity"
+	"autobid/tracing"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var proxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+// Validate reports every problem with the config at once, joined with errors.Join
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+	positive := func(key string, v float64) {
+		if v <= 0 {
+			add(key, "must be positive, got %v", v)
+		}
+	}
+	notNegative := func(key string, v float64) {
+		if v < 0 {
+			add(key, "must not be negative, got %v", v)
+		}
+	}
+	percent := func(key string, v float64) {
+		if v < 0 || v > 100 {
+			add(key, "must be a percentage in [0, 100], got %v", v)
+		}
+	}
+	patterns := func(key string, list []string) {
+		for i, p := range list {
+			if _, err := match.Compile(p); err != nil {
+				add(fmt.Sprintf("%s[%d]", key, i), "%v", err)
+			}
+		}
+	}
+	rules := func(key string, list []Match) {
+		for i, m := range list {
+			k := fmt.Sprintf("%s[%d]", key, i)
+			if m.Collection == "" && m.Model == "" && m.Backdrop == "" {
+				add(k, "needs a collection, model or backdrop pattern")
+			}
+			if _, err := match.NewRule(m.Collection, m.Model, m.Backdrop); err != nil {
+				add(k, "%v", err)
+			}
+		}
+	}
+	rareTraits := func(key string, list []RareTrait) {
+		for i, t := range list {
+			k := fmt.Sprintf("%s[%d]", key, i)
+			if t.Model == "" && t.Backdrop == "" && t.Symbol == "" && t.MaxModelRarity == 0 && t.MaxBackdropRarity == 0 && t.MaxSymbolRarity == 0 {
+				add(k, "needs a model, backdrop or symbol pattern or rarity")
+			}
+			if _, err := rarity.NewRule(t.Model, t.Backdrop, t.Symbol, t.Floor); err != nil {
+				add(k, "%v", err)
+			}
+			percent(k+".max_model_rarity", t.MaxModelRarity)
+			percent(k+".max_backdrop_rarity", t.MaxBackdropRarity)
+			percent(k+".max_symbol_rarity", t.MaxSymbolRarity)
+		}
+	}
+	overrides := func(key string, list []Override) {
+		for i, o := range list {
+			k := fmt.Sprintf("%s[%d]", key, i)
+			if o.Collection == "" {
+				add(k+".collection", "required")
+			} else if _, err := match.Compile(o.Collection); err != nil {
+				add(k+".collection", "%v", err)
+			}
+			if o.MinProfit != nil {
+				notNegative(k+".min_profit", *o.MinProfit)
+			}
+			if o.MinProfitTon != nil {
+				notNegative(k+".min_profit_ton", *o.MinProfitTon)
+			}
+			if o.MaxBid != nil {
+				positive(k+".max_bid", *o.MaxBid)
+			}
+		}
+	}
+
+	if c.Token == "" {
+		add("token", "required, set it in config.json or the TOKEN env")
+	}
+	if c.ChatID == 0 {
+		add("chat_id", "required, set it in config.json or the CHAT_ID env (a numeric id)")
+	}
+	if c.Mode != ModeAuctions && c.Mode != ModeListings {
+		add("mode", "unknown mode %q, expected %q or %q", c.Mode, ModeAuctions, ModeListings)
+	}
+
+	positive("gifts_per_fetch", float64(c.GiftsPerFetch))
+	positive("concurrent_requests", float64(c.ConcurrentRequests))
+	notNegative("min_profit", c.MinProfit)
+	notNegative("min_profit_ton", c.MinProfitTon)
+	notNegative("min_auction_end", c.MinAuctionEnd)
+	notNegative("max_auction_end", c.MaxAuctionEnd)
+	if c.MaxAuctionEnd > 0 && c.MaxAuctionEnd < c.MinAuctionEnd {
+		add("max_auction_end", "%v is below min_auction_end %v", c.MaxAuctionEnd, c.MinAuctionEnd)
+	}
+	if c.MaxBids > 0 && c.MaxBids < c.MinBids {
+		add("max_bids", "%d is below min_bids %d", c.MaxBids, c.MinBids)
+	}
+	notNegative("max_bid", c.MaxBid)
+	rules("allow", c.Allow)
+	rules("deny", c.Deny)
+	overrides("overrides", c.Overrides)
+	patterns("rare_backdrops", c.RareBackdrops)
+	rareTraits("rare_traits", c.RareTraits)
+	percent("max_model_rarity", c.MaxModelRarity)
+	percent("max_combined_rarity", c.MaxCombinedRarity)
+	positive("expiration", c.Expiration)
+	if c.Mode == ModeListings {
+		positive("scan_interval", c.ScanInterval)
+	}
+	for key, fee := range map[string]float64{"tonnel_fee": c.TonnelFee, "portals_fee": c.PortalsFee} {
+		if fee < 0 || fee >= 1 {
+			add(key, "must be a fraction in [0, 1), got %v", fee)
+		}
+	}
+
+	if c.Mode == ModeAuctions && len(c.FloorSources) == 0 {
+		add("floor_sources", "at least one source is needed in %s mode", ModeAuctions)
+	}
+	for _, name := range c.FloorSources {
+		if !isSource(name) {
+			add("floor_sources", "unknown source %q, expected %q or %q", name, pricing.SourceTonnel, pricing.SourcePortals)
+		}
+	}
+	if _, err := pricing.PolicyByName(c.FloorPolicy); err != nil {
+		add("floor_policy", "%v, expected %q, %q or %q", err, pricing.PolicyMin, pricing.PolicyMedian, pricing.PolicyWeighted)
+	}
+	if _, err := pricing.EstimatorByName(c.FloorEstimator); err != nil {
+		add("floor_estimator", "%v", err)
+	}
+	notNegative("liquidity_band", c.LiquidityBand)
+	notNegative("sales_window", c.SalesWindow)
+	if c.SalesWindow > 0 {
+		positive("recent_sales_ttl", c.RecentSalesTTL)
+	}
+	notNegative("min_near_floor", float64(c.MinNearFloor))
+	notNegative("min_recent_sales", float64(c.MinRecentSales))
+	notNegative("sales_interval", c.SalesInterval)
+	if c.SalesInterval > 0 {
+		positive("sales_per_fetch", float64(c.SalesPerFetch))
+	}
+	notNegative("fair_value_window", c.FairValueWindow)
+	notNegative("fair_value_min_sales", float64(c.FairValueMinSales))
+	notNegative("retention", c.Retention)
+	if c.Retention > 0 {
+		positive("prune_interval", c.PruneInterval)
+		if c.Retention < c.SalesWindow {
+			add("retention", "%v is below sales_window %v, the sales it counts would be pruned", c.Retention, c.SalesWindow)
+		}
+		if c.Retention < c.FairValueWindow {
+			add("retention", "%v is below fair_value_window %v, the sales it counts would be pruned", c.Retention, c.FairValueWindow)
+		}
+	}
+	if c.PaperTrading {
+		if c.Mode != ModeAuctions {
+			add("paper_trading", "only supported in %s mode", ModeAuctions)
+		}
+		if c.SalesInterval <= 0 {
+			add("paper_trading", "needs sales_interval, auctions are settled by the recorded sales")
+		}
+		positive("paper_interval", c.PaperInterval)
+		notNegative("paper_settle_after", c.PaperSettleAfter)
+	}
+
+	notNegative("min_poll_interval", c.MinPollInterval)
+	notNegative("max_poll_interval", c.MaxPollInterval)
+	if c.MaxPollInterval > 0 && c.MaxPollInterval < c.MinPollInterval {
+		add("max_poll_interval", "%v is below min_poll_interval %v", c.MaxPollInterval, c.MinPollInterval)
+	}
+	notNegative("retry_backoff", c.RetryBackoff)
+	notNegative("max_retry_backoff", c.MaxRetryBackoff)
+	notNegative("error_alert_after", float64(c.ErrorAlertAfter))
+	notNegative("shutdown_timeout", c.ShutdownTimeout)
+
+	if c.ListenAddr != "" {
+		if _, _, err := net.SplitHostPort(c.ListenAddr); err != nil {
+			add("listen_addr", "%v, expected host:port such as \":8080\"", err)
+		}
+	}
+	if c.APIToken != "" && c.ListenAddr == "" {
+		add("api_token", "the api is served on listen_addr, which is empty")
+	}
+	positive("max_scan_age", c.MaxScanAge)
+	if f := strings.ToLower(c.LogFormat); f != "text" && f != "json" {
+		add("log_format", "unknown format %q, expected \"text\" or \"json\"", c.LogFormat)
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		add("log_level", "unknown level %q, expected debug, info, warn or error", c.LogLevel)
+	}
+	switch c.TraceExporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+	case tracing.ExporterOTLP:
+		if _, _, err := net.SplitHostPort(c.TraceEndpoint); err != nil {
+			add("trace_endpoint", "%v, expected host:port of the collector", err)
+		}
+	default:
+		add("trace_exporter", "unknown exporter %q, expected %q, %q or empty", c.TraceExporter, tracing.ExporterOTLP, tracing.ExporterStdout)
+	}
+	if c.TraceSampleRatio < 0 || c.TraceSampleRatio > 1 {
+		add("trace_sample_ratio", "must be in [0, 1], got %v", c.TraceSampleRatio)
+	}
+
+	positive("cache_size", float64(c.CacheSize))
+	for source, ttl := range c.CacheTTLs {
+		if !isSource(source) {
+			add("cache_ttl", "unknown source %q, expected %q or %q", source, pricing.SourceTonnel, pricing.SourcePortals)
+		}
+		positive("cache_ttl."+source, ttl)
+	}
+	notNegative("cache_stale", c.CacheStale)
+
+	names := map[string]bool{}
+	for i, s := range c.Strategies {
+		prefix := fmt.Sprintf("strategies[%d]", i)
+		if s.Name == "" {
+			add(prefix+".name", "required")
+		} else {
+			prefix = "strategies." + s.Name
+			if names[s.Name] {
+				add(prefix, "duplicate strategy name")
+			}
+			names[s.Name] = true
+		}
+		patterns(prefix+".collections", s.Collections)
+		patterns(prefix+".exclude", s.Exclude)
+		rules(prefix+".allow", s.Allow)
+		rules(prefix+".deny", s.Deny)
+		overrides(prefix+".overrides", s.Overrides)
+		patterns(prefix+".rare_backdrops", s.RareBackdrops)
+		rareTraits(prefix+".rare_traits", s.RareTraits)
+		for key, v := range map[string]*float64{"max_model_rarity": s.MaxModelRarity, "max_combined_rarity": s.MaxCombinedRarity} {
+			if v != nil {
+				percent(prefix+"."+key, *v)
+			}
+		}
+		for key, v := range map[string]*float64{"min_profit": s.MinProfit, "min_profit_ton": s.MinProfitTon, "max_bid": s.MaxBid, "min_auction_end": s.MinAuctionEnd, "max_auction_end": s.MaxAuctionEnd} {
+			if v != nil {
+				notNegative(prefix+"."+key, *v)
+			}
+		}
+		for key, v := range map[string]*int{"min_near_floor": s.MinNearFloor, "min_recent_sales": s.MinRecentSales} {
+			if v != nil {
+				notNegative(prefix+"."+key, float64(*v))
+			}
+		}
+		if minBids, maxBids := or(s.MinBids, c.MinBids), or(s.MaxBids, c.MaxBids); maxBids > 0 && maxBids < minBids {
+			add(prefix+".max_bids", "%d is below min_bids %d", maxBids, minBids)
+		}
+		if minEnd, maxEnd := or(s.MinAuctionEnd, c.MinAuctionEnd), or(s.MaxAuctionEnd, c.MaxAuctionEnd); maxEnd > 0 && maxEnd < minEnd {
+			add(prefix+".max_auction_end", "%v is below min_auction_end %v", maxEnd, minEnd)
+		}
+	}
+
+	for i, p := range c.Proxies {
+		if err := validateProxy(p); err != nil {
+			// the proxy itself is not printed, it may contain credentials
+			add(fmt.Sprintf("proxies[%d]", i), "%v", err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func isSource(name string) bool {
+	return strings.EqualFold(name, pricing.SourceTonnel) || strings.EqualFold(name, pricing.SourcePortals)
+}
+
+func validateProxy(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return errors.New("not a valid URL")
+	}
+	if !slices.Contains(proxySchemes, u.Scheme) {
+		return fmt.Errorf("unsupported scheme %q, expected one of %s", u.Scheme, strings.Join(proxySchemes, ", "))
+	}
+	if u.Hostname() == "" || u.Port() == "" {
+		return errors.New("expected scheme://[user:password@]host:port")
+	}
+	return nil
+}
+
+// or returns *v, or def when v is unset
+func or[T any](v *T, def T) T {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+// the fields of struct type t by config key
+func fieldsByKey(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := range t.NumField() {
+		fields[t.Field(i).Tag.Get("mapstructure")] = t.Field(i).Type
+	}
+	return fields
+}
+
+// checks the keys of the config file, viper silently ignores unknown ones
+func unknownKeys(file string) error {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return checkKeys("", raw, reflect.TypeOf(Config{}))
+}
+
+// checks the keys of the object raw against struct type t, descending into
+// nested objects and lists of objects
+func checkKeys(prefix string, raw json.RawMessage, t reflect.Type) error {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		// wrong types are reported when decoding
+		return nil
+	}
+	fields := fieldsByKey(t)
+
+	names := make([]string, 0, len(doc))
+	for key := range doc {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, key := range names {
+		field, ok := fields[strings.ToLower(key)]
+		if !ok {
+			if suggestion := closest(key, fields); suggestion != "" {
+				errs = append(errs, fmt.Errorf("%s%s: unknown key, did you mean %q?", prefix, key, suggestion))
+			} else {
+				errs = append(errs, fmt.Errorf("%s%s: unknown key", prefix, key))
+			}
+			continue
+		}
+
+		switch {
+		case field.Kind() == reflect.Struct:
+			errs = append(errs, checkKeys(prefix+key+".", doc[key], field))
+		case field.Kind() == reflect.Slice && field.Elem().Kind() == reflect.Struct:
+			var items []json.RawMessage
+			if json.Unmarshal(doc[key], &items) == nil {
+				for i, item := range items {
+					errs = append(errs, checkKeys(fmt.Sprintf("%s%s[%d].", prefix, key, i), item, field.Elem()))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// the known key within a third of key's length in edits, if any
+func closest(key string, known map[string]reflect.Type) string {
+	best, bestDist := "", len(key)/3+1
+	for k := range known {
+		if d := distance(strings.ToLower(key), k); d < bestDist || (d == bestDist && k < best) {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// levenshtein distance
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
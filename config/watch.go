@@ -0,0 +1,195 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// never logged, only reported as changed
+var secretKeys = map[string]bool{
+	"redis_password": true,
+	"proxies":        true,
+	"token":          true,
+	"portals_auth":   true,
+	"api_token":      true,
+}
+
+type Change struct {
+	Key string
+	Old string
+	New string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Key, c.Old, c.New)
+}
+
+// Diff lists the keys whose values differ between a and b
+func Diff(a, b *Config) []Change {
+	changes := []Change{}
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	for i := range va.NumField() {
+		key := va.Type().Field(i).Tag.Get("mapstructure")
+		fa, fb := va.Field(i).Interface(), vb.Field(i).Interface()
+		if reflect.DeepEqual(fa, fb) {
+			continue
+		}
+		if secretKeys[key] {
+			changes = append(changes, Change{Key: key, Old: "***", New: "***"})
+			continue
+		}
+		changes = append(changes, Change{Key: key, Old: format(fa), New: format(fb)})
+	}
+	return changes
+}
+
+// Values returns the values of the given keys, unknown keys are left out
+func (c *Config) Values(keys ...string) map[string]any {
+	values := map[string]any{}
+	v := reflect.ValueOf(c).Elem()
+	for i := range v.NumField() {
+		key := v.Type().Field(i).Tag.Get("mapstructure")
+		if slices.Contains(keys, key) {
+			values[key] = v.Field(i).Interface()
+		}
+	}
+	return values
+}
+
+// lists and strategies are shown as json, pointers would print as addresses
+func format(v any) string {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Slice, reflect.Map, reflect.Struct, reflect.Pointer:
+		if raw, err := json.Marshal(v); err == nil {
+			return string(raw)
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+// Watcher applies config changes made in the file or through Set. Both
+// rebuild the config on a viper of their own, the file is watched by another
+// one, so the global viper is left alone after LoadConfig.
+type Watcher struct {
+	mu         sync.Mutex
+	file       string
+	current    *Config
+	values     map[string]any // from Set, win over the file
+	reloadable map[string]bool
+	pending    []Change // to keys that need a restart, logged once
+	apply      func(old, new *Config, changes []Change) error
+}
+
+// Watch reloads the config file whenever it is written. Edits that fail to
+// parse or validate are logged and ignored, otherwise apply is called with
+// the previous and the new config. apply returning an error rejects the edit.
+// Keys missing from reloadable keep the value the bot started with, their
+// changes are logged as needing a restart.
+func Watch(current *Config, reloadable map[string]bool, apply func(old, new *Config, changes []Change) error) *Watcher {
+	w := &Watcher{file: viper.ConfigFileUsed(), current: current, values: map[string]any{}, reloadable: reloadable, apply: apply}
+	fv := viper.New()
+	fv.SetConfigFile(w.file)
+	fv.OnConfigChange(func(e fsnotify.Event) {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		changes, err := w.update(w.values)
+		if err != nil {
+			slog.Error("config reload rejected", "file", e.Name, "err", err)
+			return
+		}
+		if len(changes) == 0 {
+			return
+		}
+		attrs := make([]string, len(changes))
+		for i, c := range changes {
+			attrs[i] = c.String()
+		}
+		slog.Info("config reloaded", "file", e.Name, "changes", attrs)
+	})
+	fv.WatchConfig()
+	return w
+}
+
+// Current is the config in effect
+func (w *Watcher) Current() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Set changes keys at runtime. The new config is validated and passed to
+// apply like a file edit, nothing changes when either fails. Set values win
+// over config.json, also on later reloads, until the bot restarts.
+func (w *Watcher) Set(values map[string]any) ([]Change, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	merged := maps.Clone(w.values)
+	maps.Copy(merged, values)
+	return w.update(merged)
+}
+
+// reads the file again with the command line overrides and values on top,
+// and applies the result. Called with w.mu held.
+func (w *Watcher) update(values map[string]any) ([]Change, error) {
+	v := viper.New()
+	v.SetConfigFile(w.file)
+	setDefaults(v)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	for key, val := range overridden {
+		v.Set(key, val)
+	}
+	for key, val := range values {
+		v.Set(key, val)
+	}
+	next, err := load(v)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, pending := []Change{}, []Change{}
+	for _, c := range Diff(w.current, next) {
+		if w.reloadable[c.Key] {
+			changes = append(changes, c)
+		} else {
+			pending = append(pending, c)
+		}
+	}
+	keep(next, w.current, pending)
+	if len(changes) > 0 {
+		if err := w.apply(w.current, next, changes); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range pending {
+		if !slices.Contains(w.pending, c) {
+			slog.Warn("config change needs a restart", "key", c.Key)
+		}
+	}
+	w.current = next
+	w.values = values
+	w.pending = pending
+	return changes, nil
+}
+
+// copies the changed fields back from the running config
+func keep(next, running *Config, changes []Change) {
+	vn, vr := reflect.ValueOf(next).Elem(), reflect.ValueOf(running).Elem()
+	for i := range vn.NumField() {
+		key := vn.Type().Field(i).Tag.Get("mapstructure")
+		if slices.ContainsFunc(changes, func(c Change) bool { return c.Key == key }) {
+			vn.Field(i).Set(vr.Field(i))
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// replaced in one step so reloads never see a half written file
+func writeConfig(t *testing.T, dir, raw string) {
+	tmp := filepath.Join(dir, "config.tmp")
+	if err := os.WriteFile(tmp, []byte(raw), 0o600); err != nil {
+		t.Error(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, "config.json")); err != nil {
+		t.Error(err)
+	}
+}
+
+// watches dir with every applied config sent to the returned channel
+func watchConfig(t *testing.T, dir string, reloadable map[string]bool) (*Watcher, <-chan *Config) {
+	// viper keeps the file found by the first LoadConfig
+	viper.SetConfigFile(filepath.Join(dir, "config.json"))
+	cfg, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied := make(chan *Config, 100)
+	w := Watch(cfg, reloadable, func(old, new *Config, changes []Change) error {
+		select {
+		case applied <- new:
+		default:
+			t.Error("applied configs are not read")
+		}
+		return nil
+	})
+	return w, applied
+}
+
+// waits for a config passing ok to be applied
+func waitApplied(t *testing.T, applied <-chan *Config, ok func(cfg *Config) bool) *Config {
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case cfg := <-applied:
+			if ok(cfg) {
+				return cfg
+			}
+		case <-timeout:
+			t.Fatal("no matching config applied")
+		}
+	}
+}
+
+// run with -race, Set used to read and write the global viper while the
+// watcher goroutine reread the file
+func TestSetDuringReload(t *testing.T) {
+	dir := t.TempDir()
+	edit := func(nearFloor int) {
+		writeConfig(t, dir, fmt.Sprintf(`{"token": "1:a", "chat_id": 1, "min_near_floor": %d}`, nearFloor))
+	}
+	edit(0)
+	w, applied := watchConfig(t, dir, map[string]bool{"min_near_floor": true, "min_bids": true})
+
+	const n = 20
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range n {
+			edit(i + 1)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := range n {
+			if _, err := w.Set(map[string]any{"min_bids": float64(i + 1)}); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	wg.Wait()
+
+	// the last edit and the last Set both stay in effect
+	waitApplied(t, applied, func(cfg *Config) bool { return cfg.MinNearFloor == n && cfg.MinBids == n })
+	if cfg := w.Current(); cfg.MinNearFloor != n || cfg.MinBids != n {
+		t.Errorf("min_near_floor %d, min_bids %d, want both %d", cfg.MinNearFloor, cfg.MinBids, n)
+	}
+}
+
+// keys that need a restart keep the value the bot runs with
+func TestReloadKeepsRestartKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, `{"token": "1:a", "chat_id": 1, "db_path": "a.db"}`)
+	w, applied := watchConfig(t, dir, map[string]bool{"min_bids": true})
+
+	writeConfig(t, dir, `{"token": "1:b", "chat_id": 1, "db_path": "b.db", "min_bids": 2}`)
+	cfg := waitApplied(t, applied, func(cfg *Config) bool { return cfg.MinBids == 2 })
+	for _, got := range []*Config{cfg, w.Current()} {
+		if got.DBPath != "a.db" || got.Token != "1:a" {
+			t.Errorf("db_path %q, token %q, want the ones in use", got.DBPath, got.Token)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package dashboard
+
+import (
+	"autobid/pricing"
+	"autobid/scanner"
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	listingTTL = 10 * time.Minute // listings are shown this long after they were last checked
+	endedTTL   = time.Minute      // auctions are shown this long after they ended
+	maxRows    = 500
+	minPush    = time.Second // between two updates sent to one client
+	keepAlive  = 15 * time.Second
+)
+
+// Row is the latest evaluation of one gift by one strategy
+type Row struct {
+	scanner.Opportunity
+	Reason string // why no alert was sent, empty for alerts
+}
+
+// Dashboard tracks what the scanner sees and serves it as a live page
+type Dashboard struct {
+	mu      sync.Mutex
+	rows    map[string]Row
+	clients map[chan struct{}]bool
+}
+
+func New() *Dashboard {
+	return &Dashboard{rows: map[string]Row{}, clients: map[chan struct{}]bool{}}
+}
+
+// Observe implements scanner.Observer
+func (d *Dashboard) Observe(o scanner.Opportunity, reason string) {
+	key := strings.Join([]string{o.Kind, o.Market, o.ID, o.SellMarket, o.Strategy}, ":")
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rows[key] = Row{Opportunity: o, Reason: reason}
+	// pruned here too, nobody may be watching for a long time
+	d.expire(time.Now())
+	for len(d.rows) > maxRows {
+		d.dropOldest()
+	}
+	for c := range d.clients {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Rows returns the tracked gifts, alerts and soonest ending auctions first
+func (d *Dashboard) Rows(now time.Time) []Row {
+	d.mu.Lock()
+	d.expire(now)
+	rows := make([]Row, 0, len(d.rows))
+	for _, r := range d.rows {
+		rows = append(rows, r)
+	}
+	d.mu.Unlock()
+
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if (a.Reason == "") != (b.Reason == "") {
+			return a.Reason == ""
+		}
+		if !a.EndsAt.Equal(b.EndsAt) {
+			return a.EndsAt.Before(b.EndsAt)
+		}
+		return a.FoundAt.After(b.FoundAt)
+	})
+	if len(rows) > maxRows {
+		rows = rows[:maxRows]
+	}
+	return rows
+}
+
+// removes ended auctions and stale listings, d.mu must be held
+func (d *Dashboard) expire(now time.Time) {
+	for key, r := range d.rows {
+		if r.Kind == scanner.KindAuction && now.Sub(r.EndsAt) > endedTTL || r.Kind == scanner.KindListing && now.Sub(r.FoundAt) > listingTTL {
+			delete(d.rows, key)
+		}
+	}
+}
+
+// removes the row checked longest ago, d.mu must be held
+func (d *Dashboard) dropOldest() {
+	oldest := ""
+	for key, r := range d.rows {
+		if oldest == "" || r.FoundAt.Before(d.rows[oldest].FoundAt) {
+			oldest = key
+		}
+	}
+	delete(d.rows, oldest)
+}
+
+// Page serves the dashboard, rendered on the server
+func (d *Dashboard) Page() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := page.Execute(w, d.view(time.Now())); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Events streams the re-rendered table body as Server-Sent Events whenever
+// an evaluation comes in, at most once per second
+func (d *Dashboard) Events() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+
+		updates := make(chan struct{}, 1)
+		updates <- struct{}{}
+		d.mu.Lock()
+		d.clients[updates] = true
+		d.mu.Unlock()
+		defer func() {
+			d.mu.Lock()
+			delete(d.clients, updates)
+			d.mu.Unlock()
+		}()
+
+		ping := time.NewTicker(keepAlive)
+		defer ping.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ping.C:
+				fmt.Fprint(w, ": ping\n\n")
+			case <-updates:
+				var buf bytes.Buffer
+				if err := page.ExecuteTemplate(&buf, "rows", d.view(time.Now())); err != nil {
+					return
+				}
+				fmt.Fprint(w, "event: rows\n")
+				for _, line := range strings.Split(buf.String(), "\n") {
+					fmt.Fprintf(w, "data: %s\n", line)
+				}
+				fmt.Fprint(w, "\n")
+				flusher.Flush()
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(minPush):
+				}
+				continue
+			}
+			flusher.Flush()
+		}
+	})
+}
+
+type view struct {
+	Rows    []Row
+	Alerts  int
+	Updated time.Time
+}
+
+func (d *Dashboard) view(now time.Time) view {
+	v := view{Rows: d.Rows(now), Updated: now}
+	for _, r := range v.Rows {
+		if r.Reason == "" {
+			v.Alerts++
+		}
+	}
+	return v
+}
+
+// floors of every source known for the row
+func floors(r Row) []pricing.Quote {
+	quotes := []pricing.Quote{}
+	if len(r.Quote.Parts) > 0 {
+		quotes = append(quotes, r.Quote.Parts...)
+	} else if r.Quote.Source != "" {
+		quotes = append(quotes, r.Quote)
+	}
+	for _, q := range r.Others {
+		found := false
+		for _, known := range quotes {
+			found = found || known.Source == q.Source
+		}
+		if !found {
+			quotes = append(quotes, q)
+		}
+	}
+	return quotes
+}
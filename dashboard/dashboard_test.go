@@ -0,0 +1,37 @@
+package dashboard
+
+import (
+	"autobid/scanner"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// rows are bounded even when the page is never opened
+func TestObservePrunes(t *testing.T) {
+	d := New()
+	now := time.Now()
+	d.Observe(scanner.Opportunity{Kind: scanner.KindAuction, ID: "ended", EndsAt: now.Add(-2 * endedTTL), FoundAt: now}, "auction ended")
+	d.Observe(scanner.Opportunity{Kind: scanner.KindListing, ID: "stale", FoundAt: now.Add(-2 * listingTTL)}, "below threshold")
+	if len(d.rows) != 0 {
+		t.Errorf("%d rows left, want ended and stale ones removed", len(d.rows))
+	}
+
+	for i := range 2 * maxRows {
+		d.Observe(scanner.Opportunity{
+			Kind:    scanner.KindAuction,
+			ID:      strconv.Itoa(i),
+			EndsAt:  now.Add(time.Hour),
+			FoundAt: now.Add(time.Duration(i) * time.Millisecond),
+		}, "below threshold")
+	}
+	if len(d.rows) != maxRows {
+		t.Fatalf("%d rows, want %d", len(d.rows), maxRows)
+	}
+	// the oldest ones were dropped
+	for _, r := range d.rows {
+		if id, _ := strconv.Atoi(r.ID); id < maxRows {
+			t.Fatalf("row %s kept, want only the latest %d", r.ID, maxRows)
+		}
+	}
+}
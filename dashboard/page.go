@@ -0,0 +1,24 @@
+package dashboard
+
+import (
+	"autobid/pricing"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"strconv"
+	"time"
+)
+
+//go:embed page.html
+var pageHTML string
+
+var page = template.Must(template.New("page").Funcs(template.FuncMap{
+	"floors":  floors,
+	"nftURL":  func(r Row) string { return fmt.Sprintf("https://t.me/nft/%s-%d", pricing.ShortName(r.Name), r.Num) },
+	"ton":     func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) },
+	"pct":     func(v float64) string { return strconv.FormatFloat(v*100, 'f', 1, 64) },
+	"unixMs":  func(t time.Time) int64 { return t.UnixMilli() },
+	"ago":     func(t time.Time) string { return time.Since(t).Round(time.Second).String() },
+	"clock":   func(t time.Time) string { return t.Format("15:04:05") },
+	"hasTime": func(t time.Time) bool { return !t.IsZero() },
+}).Parse(pageHTML))
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"autobid/logging"
+	"autobid/pricing"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+type floorRow struct {
+	Source string         `json:"source"`
+	Quote  *pricing.Quote `json:"quote,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// looks up the floor of one gift on every market, without the cache
+func runFloor(args []string) {
+	fs := flag.NewFlagSet("floor", flag.ExitOnError)
+	model := fs.String("model", "", "model, with or without the rarity suffix")
+	backdrop := fs.String("backdrop", "", "backdrop, with or without the rarity suffix")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	cfg, positional := mustLoadConfig(fs, args)
+	if len(positional) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: floor <gift> [-model M] [-backdrop B] [-json]")
+		os.Exit(2)
+	}
+	key := pricing.GiftKey{Name: strings.Join(positional, " "), Model: *model, Backdrop: *backdrop}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	sources, err := priceSources(cfg, mustParseProxies(cfg), nil)
+	if err != nil {
+		logging.Fatal("configuration error", "err", err)
+	}
+	names := []string{}
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rows := make([]floorRow, len(names))
+	failed := 0
+	for i, name := range names {
+		rows[i].Source = name
+		q, err := sources[name].Floor(ctx, key)
+		if err != nil {
+			rows[i].Error = err.Error()
+			failed++
+			continue
+		}
+		rows[i].Quote = &q
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(rows)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "source\tkey\tfloor\tlowest\tnext\tlistings\tnear_floor\trecent_sales")
+		for _, r := range rows {
+			if r.Quote == nil {
+				fmt.Fprintf(w, "%s\t%s\terror: %s\n", r.Source, key, r.Error)
+				continue
+			}
+			q := r.Quote
+			fmt.Fprintf(w, "%s\t%s\t%f\t%f\t%f\t%d\t%d\t%d\n", r.Source, q.Key, q.Price, q.Lowest, q.Depth, q.Listings, q.NearFloor, q.RecentSales)
+		}
+		w.Flush()
+	}
+	if failed == len(rows) {
+		os.Exit(1)
+	}
+}
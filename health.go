@@ -0,0 +1,147 @@
+package main
+
+import (
+	"autobid/health"
+	"autobid/scanner"
+	"autobid/telegram"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const checkTimeout = 5 * time.Second
+
+// liveness, fails once the scanner has not succeeded for maxAge
+func scanCheck(engine *scanner.Engine, maxAge time.Duration) health.Check {
+	return func(ctx context.Context) (string, error) {
+		s := engine.Status()
+		if s.LastSuccess.IsZero() {
+			if since := time.Since(s.Started); since > maxAge {
+				return "", fmt.Errorf("no successful scan since start %s ago: %v", since.Round(time.Second), s.LastError)
+			}
+			return "waiting for the first scan", nil
+		}
+
+		age := time.Since(s.LastSuccess)
+		detail := fmt.Sprintf("last success %s ago at %s", age.Round(time.Second), s.LastSuccess.UTC().Format(time.RFC3339))
+		if age > maxAge {
+			return detail, fmt.Errorf("scanner stuck, %d failures in a row: %v", s.Failures, s.LastError)
+		}
+		return detail, nil
+	}
+}
+
+func redisCheck(rdb *redis.Client) health.Check {
+	return func(ctx context.Context) (string, error) {
+		if rdb == nil {
+			return "disabled", nil
+		}
+		start := time.Now()
+		if err := rdb.Ping(ctx).Err(); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("ping %s", time.Since(start).Round(time.Millisecond)), nil
+	}
+}
+
+// fails when none of the proxies accept a connection
+func proxyCheck(proxies []*url.URL) health.Check {
+	// the same proxy is listed once per ipify check
+	unique := []*url.URL{}
+	seen := map[string]bool{}
+	for _, p := range proxies {
+		if !seen[p.Host] {
+			seen[p.Host] = true
+			unique = append(unique, p)
+		}
+	}
+
+	return func(ctx context.Context) (string, error) {
+		if len(unique) == 0 {
+			return "direct", nil
+		}
+
+		var up atomic.Int32
+		done := make(chan struct{}, len(unique))
+		for _, p := range unique {
+			go func(p *url.URL) {
+				defer func() { done <- struct{}{} }()
+				conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", p.Host)
+				if err == nil {
+					conn.Close()
+					up.Add(1)
+				}
+			}(p)
+		}
+		for range unique {
+			<-done
+		}
+
+		detail := fmt.Sprintf("%d/%d reachable", up.Load(), len(unique))
+		if up.Load() == 0 {
+			return detail, errors.New("no proxy reachable")
+		}
+		return detail, nil
+	}
+}
+
+func telegramCheck(tgLogger *telegram.TGLogger) health.Check {
+	return func(ctx context.Context) (string, error) {
+		username, err := tgLogger.GetMe(ctx)
+		if err != nil {
+			return "", err
+		}
+		return "@" + username, nil
+	}
+}
+
+// probes the running bot, for HEALTHCHECK in images without a shell or curl
+func runHealthcheck(args []string) {
+	fs := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+	ready := fs.Bool("ready", false, "check /readyz instead of /healthz")
+	addr := fs.String("addr", "", "address the bot listens on, defaults to listen_addr")
+	cfg, _ := mustLoadConfig(fs, args)
+	if *addr == "" {
+		*addr = cfg.ListenAddr
+	}
+
+	if *addr == "" {
+		fmt.Fprintln(os.Stderr, "listen_addr is empty, the http server is disabled")
+		os.Exit(1)
+	}
+	host, port, err := net.SplitHostPort(*addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid address %q: %v\n", *addr, err)
+		os.Exit(1)
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+
+	path := "/healthz"
+	if *ready {
+		path = "/readyz"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*checkTimeout)
+	defer cancel()
+	report, err := health.Probe(ctx, "http://"+net.JoinHostPort(host, port)+path)
+	if len(report.Checks) > 0 {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,100 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check returns a short human readable detail, err marks it as failed
+type Check func(ctx context.Context) (detail string, err error)
+
+type Result struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Report struct {
+	OK     bool              `json:"ok"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Run runs every check concurrently, each bounded by timeout
+func Run(ctx context.Context, checks map[string]Check, timeout time.Duration) Report {
+	report := Report{OK: true, Checks: make(map[string]Result, len(checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			detail, err := check(ctx)
+
+			r := Result{OK: err == nil, Detail: detail}
+			if err != nil {
+				r.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = r
+			if !r.OK {
+				report.OK = false
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return report
+}
+
+// Handler responds with the JSON report, 503 if any check failed
+func Handler(checks map[string]Check, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := Run(r.Context(), checks, timeout)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !report.OK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+// Probe requests url and fails unless it answers 200
+func Probe(ctx context.Context, url string) (Report, error) {
+	var report Report
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return report, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return report, err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return report, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return report, &StatusError{StatusCode: resp.StatusCode}
+	}
+	return report, nil
+}
+
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unhealthy: status %d", e.StatusCode)
+}
@@ -0,0 +1,247 @@
+package history
+
+import (
+	"autobid/pricing"
+	"autobid/tonnel"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Sale struct {
+	GiftID   int       `json:"gift_id"`
+	Name     string    `json:"name"`
+	Model    string    `json:"model"`
+	Backdrop string    `json:"backdrop"`
+	Symbol   string    `json:"symbol"`
+	Price    float64   `json:"price"`
+	Auction  bool      `json:"auction"`
+	SoldAt   time.Time `json:"sold_at"`
+}
+
+func (s Sale) id() string {
+	return fmt.Sprintf("%d:%f", s.GiftID, s.Price)
+}
+
+// auctions are dated by their end, fixed-price sales by when they were first seen
+func SaleFromGift(g tonnel.Gift, seenAt time.Time) (Sale, bool) {
+	price := g.SalePrice()
+	if price <= 0 {
+		return Sale{}, false
+	}
+
+	s := Sale{
+		GiftID:   g.GiftID,
+		Name:     g.Name,
+		Model:    g.Model,
+		Backdrop: g.Backdrop,
+		Symbol:   g.Symbol,
+		Price:    price,
+		SoldAt:   seenAt,
+	}
+	if g.Auction != nil {
+		s.Auction = true
+		s.SoldAt = g.Auction.AuctionEndTime
+	}
+	return s, true
+}
+
+// Store keeps sales in memory and appends new ones to a JSON lines file.
+type Store struct {
+	path  string
+	mu    sync.RWMutex
+	sales []Sale
+	seen  map[string]bool
+}
+
+// path may be empty to keep sales in memory only
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, seen: map[string]bool{}}
+	if path == "" {
+		return s, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var sale Sale
+		if err := json.Unmarshal(scanner.Bytes(), &sale); err != nil {
+			return nil, fmt.Errorf("invalid sale in %s: %w", path, err)
+		}
+		s.add(sale)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Store) add(sale Sale) bool {
+	if s.seen[sale.id()] {
+		return false
+	}
+	s.seen[sale.id()] = true
+	s.sales = append(s.sales, sale)
+	return true
+}
+
+// returns the number of sales that were not known yet
+func (s *Store) Add(sales []Sale) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	added := []Sale{}
+	for _, sale := range sales {
+		if s.add(sale) {
+			added = append(added, sale)
+		}
+	}
+	if len(added) == 0 || s.path == "" {
+		return len(added), nil
+	}
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return len(added), err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, sale := range added {
+		if err := enc.Encode(sale); err != nil {
+			return len(added), err
+		}
+	}
+	return len(added), nil
+}
+
+// drops sales sold before before and rewrites the file without them,
+// returns how many were dropped
+func (s *Store) Prune(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := []Sale{}
+	for _, sale := range s.sales {
+		if sale.SoldAt.Before(before) {
+			delete(s.seen, sale.id())
+		} else {
+			kept = append(kept, sale)
+		}
+	}
+	n := len(s.sales) - len(kept)
+	s.sales = kept
+	if n == 0 || s.path == "" {
+		return n, nil
+	}
+
+	// written aside and renamed so a crash never leaves half a file
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return n, err
+	}
+	enc := json.NewEncoder(f)
+	for _, sale := range kept {
+		if err := enc.Encode(sale); err != nil {
+			f.Close()
+			return n, err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return n, err
+	}
+	return n, os.Rename(tmp, s.path)
+}
+
+// sales matching the key sold after since, most recent first
+func (s *Store) Sales(key pricing.GiftKey, since time.Time) []Sale {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matches := []Sale{}
+	for _, sale := range s.sales {
+		if sale.SoldAt.Before(since) || sale.Name != key.Name {
+			continue
+		}
+		if key.Model != "" && !strings.EqualFold(pricing.StripRarity(sale.Model), pricing.StripRarity(key.Model)) {
+			continue
+		}
+		if key.Backdrop != "" && !strings.EqualFold(pricing.StripRarity(sale.Backdrop), pricing.StripRarity(key.Backdrop)) {
+			continue
+		}
+		matches = append(matches, sale)
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].SoldAt.After(matches[j].SoldAt) })
+	return matches
+}
+
+// first auction sale of the gift that ended after since
+func (s *Store) AuctionSale(giftID int, since time.Time) (Sale, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, sale := range s.sales {
+		if sale.GiftID == giftID && sale.Auction && !sale.SoldAt.Before(since) {
+			return sale, true
+		}
+	}
+	return Sale{}, false
+}
+
+// FinalBid is the winning bid of an auction of giftID that ended after endedAfter
+func (s *Store) FinalBid(giftID int, endedAfter time.Time) (float64, bool) {
+	sale, ok := s.AuctionSale(giftID, endedAfter)
+	return sale.Price, ok
+}
+
+type FairValue struct {
+	Price float64
+	Sales int
+}
+
+// median realized price of recent sales, ok is false with fewer than minSales
+func (s *Store) FairValue(key pricing.GiftKey, since time.Time, minSales int) (FairValue, bool) {
+	sales := s.Sales(key, since)
+	if len(sales) == 0 || len(sales) < minSales {
+		return FairValue{Sales: len(sales)}, false
+	}
+
+	quotes := make([]pricing.Quote, len(sales))
+	for i, sale := range sales {
+		quotes[i] = pricing.Quote{Price: sale.Price}
+	}
+
+	return FairValue{Price: pricing.Median(quotes), Sales: len(sales)}, true
+}
+
+func Ingest(ctx context.Context, store *Store, client *tonnel.TonnelAPI, limit uint32) (int, error) {
+	gifts, err := client.GetSales(ctx, 1, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	sales := []Sale{}
+	for _, g := range gifts {
+		if sale, ok := SaleFromGift(g, now); ok {
+			sales = append(sales, sale)
+		}
+	}
+	return store.Add(sales)
+}
@@ -0,0 +1,44 @@
+package history
+
+import (
+	"autobid/pricing"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPrune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sales.jsonl")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Truncate(time.Second)
+	sales := []Sale{
+		{GiftID: 1, Name: "Cat", Price: 10, SoldAt: now.Add(-48 * time.Hour)},
+		{GiftID: 2, Name: "Cat", Price: 20, SoldAt: now.Add(-time.Hour)},
+	}
+	if _, err := s.Add(sales); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.Prune(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("pruned %d sales, want 1", n)
+	}
+
+	// the file is rewritten without the pruned sale
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, store := range map[string]*Store{"pruned": s, "reopened": reopened} {
+		got := store.Sales(pricing.GiftKey{Name: "Cat"}, time.Time{})
+		if len(got) != 1 || got[0].GiftID != 2 {
+			t.Errorf("%s: sales %+v, want only gift 2", name, got)
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package ip
 
 import (
+	"autobid/logging"
+	"autobid/metrics"
 	"autobid/tlsclient"
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/url"
 	"time"
 )
@@ -58,6 +60,7 @@ func (api *IpifyAPI) GetIp(ctx context.Context) (string, error) {
 
 		if !resp.Ok {
 			if resp.StatusCode == 429 {
+				metrics.FloodWait("api.ipify.org", api.conn.Proxy())
 				i += 1
 				if i > api.opt.FloodRetries {
 					return "", &tlsclient.FloodWaitError{
@@ -67,8 +70,12 @@ func (api *IpifyAPI) GetIp(ctx context.Context) (string, error) {
 					}
 				}
 
-				log.Printf("INFO: api.ipify.org returned 429, waiting for %f secs...", t.Seconds())
-				time.Sleep(t)
+				slog.Warn("flood wait", "origin", "api.ipify.org", logging.Proxy(api.conn.Proxy()), "duration", t)
+				select {
+				case <-ctx.Done():
+					return "", ctx.Err()
+				case <-time.After(t):
+				}
 				t *= 2
 				continue
 			}
@@ -81,3 +88,7 @@ func (api *IpifyAPI) GetIp(ctx context.Context) (string, error) {
 
 	return string(resp.Body), nil
 }
+
+func (api *IpifyAPI) Close() error {
+	return api.conn.Close()
+}
@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// userinfo of urls embedded in strings, e.g. proxy dial errors
+var userinfo = regexp.MustCompile(`(://)[^/@\s]+@`)
+
+// Setup installs the default slog logger. format is text or json, secrets are
+// replaced wherever they show up in attribute values.
+func Setup(format, level string, secrets ...string) error {
+	handler, err := NewHandler(os.Stderr, format, level, secrets...)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+func NewHandler(w io.Writer, format, level string, secrets ...string) (slog.Handler, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q", level)
+	}
+
+	r := newRedactor(secrets)
+	opts := &slog.HandlerOptions{Level: lvl, ReplaceAttr: r.replaceAttr}
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.NewTextHandler(w, opts), nil
+	case "json":
+		return slog.NewJSONHandler(w, opts), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+type redactor struct {
+	replacer *strings.Replacer
+}
+
+func newRedactor(secrets []string) *redactor {
+	pairs := []string{}
+	for _, s := range secrets {
+		if s != "" {
+			pairs = append(pairs, s, redacted)
+		}
+	}
+	return &redactor{replacer: strings.NewReplacer(pairs...)}
+}
+
+func (r *redactor) String(s string) string {
+	return userinfo.ReplaceAllString(r.replacer.Replace(s), "${1}"+redacted+"@")
+}
+
+func (r *redactor) replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(r.String(a.Value.String()))
+		return a
+	case slog.KindAny:
+	default:
+		return a
+	}
+
+	switch v := a.Value.Any().(type) {
+	case *url.URL:
+		if v != nil {
+			a.Value = slog.StringValue(r.String(v.Redacted()))
+		}
+	case error:
+		a.Value = slog.StringValue(r.String(v.Error()))
+	case fmt.Stringer:
+		a.Value = slog.StringValue(r.String(v.String()))
+	}
+	return a
+}
+
+// Fatal logs at error level and exits
+func Fatal(msg string, args ...any) {
+	slog.Default().Log(context.Background(), slog.LevelError, msg, args...)
+	os.Exit(1)
+}
+
+// Proxy is the attribute proxies are logged under, without credentials
+func Proxy(proxy *url.URL) slog.Attr {
+	if proxy == nil {
+		return slog.String("proxy", "direct")
+	}
+	return slog.String("proxy", proxy.Redacted())
+}
@@ -1,56 +1,126 @@
 package main
 
 import (
+	"autobid/api"
+	"autobid/cache"
 	"autobid/config"
+	"autobid/dashboard"
+	"autobid/health"
+	"autobid/history"
 	"autobid/ip"
-	"autobid/portal"
+	"autobid/logging"
+	"autobid/metrics"
+	"autobid/paper"
+	"autobid/pricing"
+	"autobid/scanner"
+	"autobid/store"
 	"autobid/telegram"
 	"autobid/tonnel"
+	"autobid/tracing"
 	"context"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"net/url"
-	"regexp"
-	"strconv"
+	"os"
+	"os/signal"
+	"slices"
 	"strings"
-	"sync"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
-func shortName(s string) string {
-	re := regexp.MustCompile(`[\s\W]+`)
-	return strings.ToLower(re.ReplaceAllString(s, ""))
+type command struct {
+	name  string // subcommand words
+	usage string
+	run   func(args []string)
 }
 
-func removePercentage(model string) string {
-	re := regexp.MustCompile(`\s*\([^)]*%?\)`)
-	return re.ReplaceAllString(model, "")
+var commands = []command{
+	{"scan", "run the scanner until interrupted (default)", runScan},
+	{"auctions", "list active Tonnel auctions", runAuctions},
+	{"floor", "query Tonnel and Portals floors: floor <gift> [-model M] [-backdrop B]", runFloor},
+	{"proxies check", "check every proxy and show its exit ip", runProxiesCheck},
+	{"config validate", "check config.json without starting the bot", runValidate},
+	{"replay", "replay recorded auctions against swept thresholds", runBacktest},
+	{"backtest", "same as replay", runBacktest},
+	{"healthcheck", "probe the running bot's health endpoints", runHealthcheck},
 }
 
 func main() {
-	cfg, err := config.LoadConfig(".")
+	args := os.Args[1:]
+	if len(args) > 0 && slices.Contains([]string{"help", "-h", "-help", "--help"}, args[0]) {
+		usage()
+		return
+	}
+	// flags without a command keep the old behaviour of running the scanner
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		runScan(args)
+		return
+	}
+	for _, c := range commands {
+		words := strings.Fields(c.name)
+		if len(args) >= len(words) && slices.Equal(args[:len(words)], words) {
+			c.run(args[len(words):])
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", strings.Join(args, " "))
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\ncommands:\n", os.Args[0])
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", c.name, c.usage)
+	}
+	w.Flush()
+	fmt.Fprintln(os.Stderr, "\nevery command takes -dir (directory holding config.json) and a flag per config key, e.g. -min_profit 0.1, run <command> -h to list them")
+}
+
+// runs the scanner, the bot's main mode
+func runScan(args []string) {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	cfg, positional := mustLoadConfig(fs, args)
+	if len(positional) > 0 {
+		logging.Fatal("unexpected arguments", "args", positional)
+	}
+	slog.Info("loaded config", "proxies", len(cfg.Proxies), "mode", cfg.Mode)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), &tracing.Options{
+		Exporter:    cfg.TraceExporter,
+		Endpoint:    cfg.TraceEndpoint,
+		Insecure:    cfg.TraceInsecure,
+		SampleRatio: cfg.TraceSampleRatio,
+		Service:     "autobid",
+	})
 	if err != nil {
-		log.Fatalf("configuration error: %v", err)
+		logging.Fatal("configuration error", "err", err)
 	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	proxies := []*url.URL{}
-	for _, proxyStr := range cfg.Proxies {
-		proxy, err := url.Parse(proxyStr)
-		if err != nil {
-			log.Fatalf("invalid proxy address: %s\n", err)
-		}
+	for _, proxy := range mustParseProxies(cfg) {
 		for i := range 3 {
 			ipifyClient, err := ip.New(&ip.Options{Proxies: []*url.URL{proxy}})
 			if err != nil {
-				log.Fatalf("failed to connect to api.ipify.org: %s\n", err)
+				logging.Fatal("connecting to api.ipify.org failed", logging.Proxy(proxy), "err", err)
 			}
-			ip, err := ipifyClient.GetIp(context.Background())
+			ip, err := ipifyClient.GetIp(ctx)
+			ipifyClient.Close()
 			if err != nil {
-				log.Fatalf("failed to fetch ip info: %s\n", err)
+				logging.Fatal("fetching ip info failed", logging.Proxy(proxy), "err", err)
 			}
-			log.Printf("[%s] %s (%d)...\n", proxy.String(), ip, i+1)
+			slog.Info("proxy checked", logging.Proxy(proxy), "ip", ip, "attempt", i+1)
 			proxies = append(proxies, proxy)
 		}
 	}
@@ -65,249 +135,364 @@ func main() {
 			ReadTimeout:  3 * time.Second,
 			WriteTimeout: 3 * time.Second,
 		})
-		if err := rdb.Ping(context.Background()).Err(); err != nil {
-			log.Fatalf("connection to redis failed: %v", err)
+		rdb.AddHook(tracing.RedisHook(cfg.RdbAddr))
+		if err := rdb.Ping(ctx).Err(); err != nil {
+			logging.Fatal("connecting to redis failed", "addr", cfg.RdbAddr, "err", err)
 		}
 	} else {
-		log.Printf("[warning] NO REDIS ADDRESS PROVIDED")
+		slog.Warn("no redis address provided, dedupe and cache are in memory")
 	}
 
-	tgLogger := telegram.NewLogger(cfg.Token, cfg.ChatID)
-	client, err := tonnel.New(&tonnel.Options{
-		Proxies: proxies,
-	})
+	var cacheStore cache.Store = cache.NewLRU(cfg.CacheSize)
+	if rdb != nil {
+		cacheStore = cache.NewRedis(rdb, "cache:")
+	}
+	sources, err := priceSources(cfg, proxies, cache.New(cacheStore))
 	if err != nil {
-		log.Fatalf("connection to tonnel failed: %v", err)
+		logging.Fatal("configuration error", "err", err)
 	}
 
-	for {
-		log.Printf("fetching auctions...")
-		gifts, err := client.GetAuctions(context.Background(), 1+cfg.GiftsOffset, cfg.GiftsPerFetch)
+	var db *store.DB
+	if cfg.DBPath != "" {
+		db, err = store.Open(cfg.DBPath)
 		if err != nil {
-			log.Fatalf("error GetAuctions: %v", err)
+			logging.Fatal("opening database failed", "path", cfg.DBPath, "err", err)
+		}
+		for name, src := range sources {
+			sources[name] = db.Recording(src)
 		}
+	}
+	for name, src := range sources {
+		sources[name] = pricing.Instrumented(name, src)
+	}
 
-		var latest time.Time
-		filteredGifts := []tonnel.Gift{}
-		for _, g := range gifts {
-			now := time.Now()
-			end := g.Auction.AuctionEndTime
-			if now.Add(time.Duration(cfg.MinAuctionEnd*float64(time.Second))).After(end) || g.GiftID < 0 {
-				continue
-			}
-			if len(g.Auction.BidHistory) < int(cfg.MinBids) {
-				continue
-			}
-			if end.After(latest) {
-				latest = end
-			}
-			filteredGifts = append(filteredGifts, g)
+	sales, err := history.Open(cfg.SalesFile)
+	if err != nil {
+		logging.Fatal("loading sales history failed", "path", cfg.SalesFile, "err", err)
+	}
+	if cfg.SalesInterval > 0 {
+		go pollSales(ctx, cfg, proxies, sales)
+	}
+	if cfg.Retention > 0 {
+		go pruneHistory(ctx, cfg, db, sales)
+	}
+
+	rules, err := scanRules(cfg, sources)
+	if err != nil {
+		logging.Fatal("configuration error", "err", err)
+	}
+
+	queue := telegram.NewQueue(telegram.NewLogger(cfg.Token, cfg.ChatID), 100)
+	metrics.Gauge("telegram_queue_length", "Telegram messages waiting to be sent.", func() float64 {
+		return float64(queue.Len())
+	})
+	notifier := &telegramNotifier{
+		cfg:   cfg,
+		queue: queue,
+		sales: sales,
+		db:    db,
+	}
+	if cfg.PaperTrading {
+		opt := &paper.Options{
+			FinalBids:   sales,
+			Floor:       rules.Floor,
+			SettleAfter: time.Duration(cfg.PaperSettleAfter * float64(time.Second)),
 		}
-		earliest := latest
-		for _, g := range filteredGifts {
-			end := g.Auction.AuctionEndTime
-			if end.Before(earliest) {
-				earliest = end
-			}
+		if db != nil {
+			opt.Store = db
+		} else {
+			slog.Warn("no db_path provided, paper positions are in memory")
 		}
+		notifier.paper, err = paper.New(opt)
+		if err != nil {
+			logging.Fatal("opening paper ledger failed", "err", err)
+		}
+		go notifier.paper.Run(ctx, time.Duration(cfg.PaperInterval*float64(time.Second)))
+		bot := telegram.NewLogger(cfg.Token, cfg.ChatID)
+		go bot.Commands(ctx, []int64{cfg.ChatID, cfg.AdminChatID}, map[string]telegram.CommandFunc{
+			"pnl": func(ctx context.Context, args string) string { return pnlMessage(notifier.paper) },
+		})
+		slog.Info("paper trading enabled")
+	}
+	opt := &scanner.Options{
+		Rules:        rules,
+		Mode:         scanner.KindAuction,
+		Markets:      sources,
+		Notifier:     notifier,
+		Dedupe:       scanner.NewDedupe(rdb, time.Duration(cfg.Expiration*float64(time.Second))),
+		Concurrency:  cfg.ConcurrentRequests,
+		ScanInterval: time.Duration(cfg.ScanInterval * float64(time.Second)),
+
+		MinPollInterval: time.Duration(cfg.MinPollInterval * float64(time.Second)),
+		MaxPollInterval: time.Duration(cfg.MaxPollInterval * float64(time.Second)),
+		RetryBackoff:    time.Duration(cfg.RetryBackoff * float64(time.Second)),
+		MaxRetryBackoff: time.Duration(cfg.MaxRetryBackoff * float64(time.Second)),
+		ErrorAlertAfter: cfg.ErrorAlertAfter,
+		Admin:           &adminAlerter{tgLogger: telegram.NewLogger(cfg.Token, cfg.AdminChatID)},
+	}
+	auctions := &scanner.TonnelAuctions{Proxies: proxies, Offset: cfg.GiftsOffset, Limit: cfg.GiftsPerFetch}
+	listings := &scanner.MarketListings{Proxies: proxies, PortalsAuth: cfg.PortalsAuth, Offset: cfg.GiftsOffset, Limit: cfg.GiftsPerFetch}
+	if db != nil {
+		auctions.Recorder = db
+		listings.Recorder = db
+	}
+	opt.Auctions = auctions
+	opt.Listings = listings
+	// the api serves the same tracked gifts as the dashboard
+	var dash *dashboard.Dashboard
+	if cfg.ListenAddr != "" && (cfg.Dashboard || cfg.APIToken != "") {
+		dash = dashboard.New()
+		opt.Observer = dash
+	}
+	if cfg.Mode == config.ModeListings {
+		opt.Mode = scanner.KindListing
+	}
 
-		log.Printf("found %d auctions (%fs - %fs)", len(filteredGifts), time.Until(earliest).Seconds(), time.Until(latest).Seconds())
-		ch := giftFloorGenerator(filteredGifts, proxies, cfg.RareBackdrops, cfg.ConcurrentRequests)
-		for gf := range ch {
-			if gf.Err != nil {
-				log.Printf("error GetFloor gift %d: %v", gf.Gift.GiftID, gf.Err)
-				continue
-			}
+	engine, err := scanner.New(opt)
+	if err != nil {
+		logging.Fatal("configuration error", "err", err)
+	}
+	settings := config.Watch(cfg, reloadable, func(old, new *config.Config, changes []config.Change) error {
+		return reload(engine, sources, new)
+	})
 
-			now := time.Now()
-			end := gf.Gift.Auction.AuctionEndTime
-			if now.After(end) {
-				continue
+	var server *http.Server
+	if cfg.ListenAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		if notifier.paper != nil {
+			mux.Handle("/pnl", notifier.paper.Handler())
+		}
+		if cfg.Dashboard {
+			mux.Handle("GET /{$}", dash.Page())
+			mux.Handle("GET /events", dash.Events())
+		}
+		if cfg.APIToken != "" {
+			apiOpt := &api.Options{
+				Token:   cfg.APIToken,
+				Tracker: dash,
+				Engine:  engine,
+				Proxies: proxies,
+				CheckProxy: func(ctx context.Context, proxy *url.URL) (string, error) {
+					if proxy == nil {
+						return checkProxy(ctx, nil)
+					}
+					return checkProxy(ctx, []*url.URL{proxy})
+				},
+				Settings: settings,
 			}
-
-			bid := gf.Gift.MinBid()
-			profit := gf.Floor - bid
-			profitPercentage := 1 - (bid / gf.Floor)
-			log.Printf("[%d] %s #%d = %f %s | %f %s (%f%% - %fs)\n", gf.Gift.GiftID, gf.Gift.Name, gf.Gift.GiftNum, bid, gf.Gift.Asset, gf.Floor, gf.Gift.Asset, profitPercentage*100, time.Until(end).Seconds())
-
-			if profitPercentage < cfg.MinProfit || profit < cfg.MinProfitTon {
-				continue
+			if db != nil {
+				apiOpt.Alerts = db
 			}
-
-			portalFloor, err := getPortalFloor(rdb, proxies, time.Duration(cfg.Expiration*float64(time.Second)), shortName(gf.Gift.Name), removePercentage(gf.Gift.Model), removePercentage(gf.Gift.Backdrop), cfg.RareBackdrops, context.Background())
-			portalMsg := ""
+			handler, err := api.New(apiOpt)
 			if err != nil {
-				log.Printf("[%d] warning: %v", gf.Gift.GiftID, err)
-			} else {
-				portalMsg = fmt.Sprintf("<a href=\"https://t.me/portals/market?startapp=7t5no1\">Portals</a> Floor: <b>%f</b> TON\n", portalFloor)
+				logging.Fatal("configuration error", "err", err)
 			}
-
-			d := time.Until(end)
-			hours := int(d / time.Hour)
-			d -= time.Duration(hours) * time.Hour
-			minutes := int(d / time.Minute)
-			d -= time.Duration(minutes) * time.Minute
-			seconds := int(d / time.Second)
-
-			link := fmt.Sprintf("https://t.me/nft/%s-%d", shortName(gf.Gift.Name), gf.Gift.GiftNum)
-			msg := fmt.Sprintf("<a href=\"%s\">%s #%d</a>\n\nBid Cost: <b>%f</b> %s\nMin Sell: <b>%f</b> %s\nProfit: <b>%f</b>%% (%f %s)\n%sEnd in: %02d:%02d:%02d\n\n<b><a href=\"https://t.me/portals/market?startapp=7t5no1\">Portals</a></b> | <b><a href=\"https://t.me/tonnel_network_bot/gifts?startapp=ref_438949837\">Tonnel</a></b>", link, gf.Gift.Name, gf.Gift.GiftNum, bid, gf.Gift.Asset, gf.Floor, gf.Gift.Asset, profitPercentage*100, gf.Floor-bid, gf.Gift.Asset, portalMsg, hours, minutes, seconds)
-			go tgLogger.SendMessage(context.Background(), msg, true, nil, &telegram.InlineKeyboardMarkup{
-				InlineKeyboard: [][]telegram.InlineKeyboardButton{
-					{{Text: "Place Bid", URL: fmt.Sprintf("https://t.me/tonnel_network_bot/gift?startapp=%d", gf.Gift.GiftID)}},
-				},
-			})
+			mux.Handle("/api/", handler)
+			slog.Info("rest api enabled")
 		}
 
-		t := time.Until(latest)
-		log.Printf("waiting for %f secs for new auctions...\n", t.Seconds())
-		time.Sleep(t)
-	}
-}
-
-type GiftWithFloor struct {
-	Gift  tonnel.Gift
-	Floor float64
-	Err   error
-}
-
-func giftFloorGenerator(gifts []tonnel.Gift, proxies []*url.URL, rare_backdrops []string, maxConcurrent int) <-chan GiftWithFloor {
-	out := make(chan GiftWithFloor)
-	go func() {
-		defer close(out)
-
-		sem := make(chan struct{}, maxConcurrent)
-		var wg sync.WaitGroup
-
-		for _, g := range gifts {
-			wg.Add(1)
-			go func(g tonnel.Gift) {
-				defer wg.Done()
-
-				sem <- struct{}{}
-				floor, err := getFloor(proxies, g.Name, g.Model, g.Backdrop, rare_backdrops)
-				<-sem
-
-				out <- GiftWithFloor{Gift: g, Floor: floor, Err: err}
-			}(g)
+		liveness := map[string]health.Check{
+			"scan": scanCheck(engine, time.Duration(cfg.MaxScanAge*float64(time.Second))),
 		}
+		readiness := map[string]health.Check{
+			"scan":     liveness["scan"],
+			"redis":    redisCheck(rdb),
+			"proxies":  proxyCheck(proxies),
+			"telegram": telegramCheck(telegram.NewLogger(cfg.Token, cfg.ChatID)),
+		}
+		mux.Handle("/healthz", health.Handler(liveness, checkTimeout))
+		mux.Handle("/readyz", health.Handler(readiness, checkTimeout))
+		server = serve(cfg.ListenAddr, mux)
+	}
 
-		wg.Wait()
-	}()
-	return out
-}
+	if err := engine.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		slog.Error("scanner stopped", "err", err)
+	}
 
-func getPortalFloor(rdb *redis.Client, proxies []*url.URL, expiration time.Duration, giftName, model, backdrop string, rare_backdrops []string, ctx context.Context) (float64, error) {
-	filterModel := model
-	filterBackdrop := ""
-	lowerOutput := strings.ToLower(backdrop)
-	for _, rb := range rare_backdrops {
-		if strings.ToLower(rb) == lowerOutput {
-			filterModel = ""
-			filterBackdrop = backdrop
-			break
+	slog.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout*float64(time.Second)))
+	defer cancel()
+	if err := queue.Close(shutdownCtx); err != nil {
+		slog.Warn("telegram messages not sent", "count", queue.Len(), "err", err)
+	}
+	if server != nil {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("http server shutdown failed", "err", err)
 		}
 	}
+	auctions.Close()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Warn("flushing traces failed", "err", err)
+	}
+	if db != nil {
+		db.Close()
+	}
+	if rdb != nil {
+		rdb.Close()
+	}
+}
 
-	var portalFloors portal.FloorPrices
-	var portalResPointer *portal.FloorPrices = &portalFloors
-	if rdb == nil {
-		client, err := portal.New(&portal.Options{
-			FloodRetries: 1,
-			Proxies:      proxies,
-		})
+func pollSales(ctx context.Context, cfg *config.Config, proxies []*url.URL, sales *history.Store) {
+	interval := time.Duration(cfg.SalesInterval * float64(time.Second))
+	for {
+		client, err := tonnel.New(&tonnel.Options{Proxies: proxies})
 		if err != nil {
-			return 0, err
+			slog.Error("connecting to tonnel failed", "err", err)
+		} else {
+			n, err := history.Ingest(ctx, sales, client, cfg.SalesPerFetch)
+			client.Close()
+			if err != nil {
+				slog.Error("fetching sales failed", "origin", "rs-gifts.tonnel.network", "err", err)
+			} else {
+				slog.Info("recorded sales", "count", n)
+			}
 		}
 
-		portalResPointer, err = client.GetFloor(context.Background(), giftName)
-		if err != nil {
-			return 0, err
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
 		}
-	} else {
-		key := giftName
-		raw, err := rdb.Get(ctx, key).Result()
-		if err != nil {
-			if err == redis.Nil {
-				client, err := portal.New(&portal.Options{
-					FloodRetries: 1,
-					Proxies:      proxies,
-				})
-				if err != nil {
-					return 0, err
-				}
-
-				portalResPointer, err = client.GetFloor(context.Background(), giftName)
-				if err != nil {
-					return 0, err
-				}
-				jsonData, err := json.Marshal(portalResPointer)
-				if err != nil {
-					return 0, err
-				}
-				if _, err := rdb.Set(ctx, key, jsonData, expiration).Result(); err != nil {
-					return 0, err
-				}
+	}
+}
+
+// drops records older than the retention on startup and every prune_interval
+func pruneHistory(ctx context.Context, cfg *config.Config, db *store.DB, sales *history.Store) {
+	retention := time.Duration(cfg.Retention * float64(time.Second))
+	interval := time.Duration(cfg.PruneInterval * float64(time.Second))
+	for {
+		before := time.Now().Add(-retention)
+		if db != nil {
+			n, err := db.Prune(before)
+			if err != nil {
+				slog.Error("pruning database failed", "path", cfg.DBPath, "err", err)
 			} else {
-				return 0, err
+				slog.Info("pruned database", "records", n, "before", before)
 			}
+		}
+		n, err := sales.Prune(before)
+		if err != nil {
+			slog.Error("pruning sales history failed", "path", cfg.SalesFile, "err", err)
 		} else {
-			if err := json.Unmarshal([]byte(raw), portalResPointer); err != nil {
-				return 0, err
-			}
+			slog.Info("pruned sales history", "sales", n, "before", before)
 		}
-	}
 
-	if filterModel != "" {
-		modelFloorStr, ok := portalResPointer.FloorPrices[giftName].Models[filterModel]
-		if !ok {
-			return 0, fmt.Errorf("no floor for \"%s\" (%s)", filterModel, giftName)
-		}
-		modelFloor, err := strconv.ParseFloat(modelFloorStr, 64)
-		if err != nil {
-			return 0, fmt.Errorf("invalid floor for \"%s\" (%s): %v", filterModel, giftName, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
 		}
-		return modelFloor, nil
 	}
-	backdropFloorStr, ok := portalResPointer.FloorPrices[shortName(giftName)].Models[filterBackdrop]
-	if !ok {
-		return 0, fmt.Errorf("no floor for \"%s\" (%s)", filterBackdrop, giftName)
+}
+
+// keys applied by reload, changing any other key needs a restart
+var reloadable = map[string]bool{
+	"min_profit":          true,
+	"min_profit_ton":      true,
+	"min_bids":            true,
+	"max_bids":            true,
+	"max_bid":             true,
+	"min_auction_end":     true,
+	"max_auction_end":     true,
+	"min_near_floor":      true,
+	"min_recent_sales":    true,
+	"rare_backdrops":      true,
+	"rare_traits":         true,
+	"max_model_rarity":    true,
+	"max_combined_rarity": true,
+	"tonnel_fee":          true,
+	"portals_fee":         true,
+	"floor_sources":       true,
+	"floor_policy":        true,
+	"strategies":          true,
+	"allow":               true,
+	"deny":                true,
+	"overrides":           true,
+}
+
+func scanRules(cfg *config.Config, sources map[string]pricing.PriceSource) (scanner.Rules, error) {
+	floors, others, err := floorSource(cfg, sources)
+	if err != nil {
+		return scanner.Rules{}, err
 	}
-	backdropFloor, err := strconv.ParseFloat(backdropFloorStr, 64)
+	strategies, err := scanner.StrategiesFromConfig(cfg)
 	if err != nil {
-		return 0, fmt.Errorf("invalid floor for \"%s\" (%s): %v", filterBackdrop, giftName, err)
+		return scanner.Rules{}, err
 	}
-	return backdropFloor, nil
+	return scanner.Rules{
+		Floor:      floors,
+		Others:     others,
+		Fees:       map[string]float64{pricing.SourceTonnel: cfg.TonnelFee, pricing.SourcePortals: cfg.PortalsFee},
+		Strategies: strategies,
+	}, nil
 }
 
-func getFloor(proxies []*url.URL, giftName, model, backdrop string, rare_backdrops []string) (float64, error) {
-	filterModel := model
-	filterBackdrop := ""
-	lowerOutput := strings.ToLower(removePercentage(backdrop))
-	for _, rb := range rare_backdrops {
-		if strings.ToLower(rb) == lowerOutput {
-			filterModel = ""
-			filterBackdrop = backdrop
-			break
-		}
+func reload(engine *scanner.Engine, sources map[string]pricing.PriceSource, cfg *config.Config) error {
+	rules, err := scanRules(cfg, sources)
+	if err != nil {
+		return err
 	}
+	return engine.Reload(rules)
+}
 
-	client, err := tonnel.New(&tonnel.Options{
-		Proxies: proxies,
-	})
+// Tonnel and Portals floors, c caches them when not nil
+func priceSources(cfg *config.Config, proxies []*url.URL, c *cache.Cache) (map[string]pricing.PriceSource, error) {
+	estimator, err := pricing.EstimatorByName(cfg.FloorEstimator)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	stale := time.Duration(cfg.CacheStale * float64(time.Second))
+
+	var tonnelSource pricing.PriceSource = pricing.NewTonnelSource(&pricing.TonnelOptions{
+		Proxies:     proxies,
+		Estimator:   estimator,
+		Band:        cfg.LiquidityBand,
+		SalesWindow: time.Duration(cfg.SalesWindow * float64(time.Second)),
+		Cache:       c,
+		SalesTTL:    time.Duration(cfg.RecentSalesTTL * float64(time.Second)),
+	})
+	if c != nil {
+		tonnelSource = pricing.Cached(pricing.SourceTonnel, tonnelSource, c, cfg.CacheTTL(pricing.SourceTonnel), stale)
 	}
+	return map[string]pricing.PriceSource{
+		pricing.SourceTonnel: tonnelSource,
+		pricing.SourcePortals: pricing.NewPortalsSource(&pricing.PortalsOptions{
+			Proxies: proxies,
+			Auth:    cfg.PortalsAuth,
+			Cache:   c,
+			TTL:     cfg.CacheTTL(pricing.SourcePortals),
+			Stale:   stale,
+		}),
+	}, nil
+}
 
-	gift, err := client.GetFloor(context.Background(), giftName, filterModel, filterBackdrop)
+// aggregates the configured floor sources, the rest are only shown in alerts
+func floorSource(cfg *config.Config, sources map[string]pricing.PriceSource) (pricing.PriceSource, map[string]pricing.PriceSource, error) {
+	policy, err := pricing.PolicyByName(cfg.FloorPolicy)
 	if err != nil {
-		return 0, err
+		return nil, nil, err
 	}
-	if gift == nil {
-		gift, err = client.GetFloor(context.Background(), giftName, "", "")
-		if err != nil {
-			return 0, err
+
+	aggregate := &pricing.Aggregate{Sources: map[string]pricing.PriceSource{}, Policy: policy}
+	for _, name := range cfg.FloorSources {
+		found := false
+		for sourceName, src := range sources {
+			if strings.EqualFold(sourceName, name) {
+				aggregate.Sources[sourceName] = src
+				found = true
+			}
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("unknown floor source %q", name)
 		}
 	}
 
-	return gift.Price, nil
+	others := map[string]pricing.PriceSource{}
+	for name, src := range sources {
+		if _, ok := aggregate.Sources[name]; !ok {
+			others[name] = src
+		}
+	}
+	return aggregate, others, nil
 }
@@ -0,0 +1,109 @@
+package match
+
+import (
+	"autobid/pricing"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Pattern matches gift names and traits case-insensitively. "/expr/" is a
+// regular expression, anything else a glob where * and ? are wildcards.
+// Rarity suffixes such as " (1.2%)" are ignored.
+type Pattern struct {
+	raw  string
+	glob string
+	re   *regexp.Regexp
+}
+
+func Compile(s string) (*Pattern, error) {
+	if len(s) >= 2 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/") {
+		re, err := regexp.Compile("(?i)" + s[1:len(s)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp %q: %w", s, err)
+		}
+		return &Pattern{raw: s, re: re}, nil
+	}
+
+	glob := strings.ToLower(s)
+	if _, err := path.Match(glob, ""); err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", s, err)
+	}
+	return &Pattern{raw: s, glob: glob}, nil
+}
+
+// a nil pattern matches everything
+func (p *Pattern) Match(s string) bool {
+	if p == nil {
+		return true
+	}
+	s = pricing.StripRarity(s)
+	if p.re != nil {
+		return p.re.MatchString(s)
+	}
+	ok, _ := path.Match(p.glob, strings.ToLower(s))
+	return ok
+}
+
+func (p *Pattern) String() string {
+	if p == nil {
+		return "*"
+	}
+	return p.raw
+}
+
+// Rule matches gifts whose collection, model and backdrop all match, nil
+// patterns match anything
+type Rule struct {
+	Collection *Pattern
+	Model      *Pattern
+	Backdrop   *Pattern
+}
+
+// NewRule compiles the non-empty patterns
+func NewRule(collection, model, backdrop string) (Rule, error) {
+	var r Rule
+	for _, f := range []struct {
+		s string
+		p **Pattern
+	}{{collection, &r.Collection}, {model, &r.Model}, {backdrop, &r.Backdrop}} {
+		if f.s == "" {
+			continue
+		}
+		p, err := Compile(f.s)
+		if err != nil {
+			return Rule{}, err
+		}
+		*f.p = p
+	}
+	return r, nil
+}
+
+func (r Rule) Match(name, model, backdrop string) bool {
+	return r.Collection.Match(name) && r.Model.Match(model) && r.Backdrop.Match(backdrop)
+}
+
+// Filter passes gifts matching any Allow rule, or all gifts if there are
+// none, unless they match a Deny rule
+type Filter struct {
+	Allow []Rule
+	Deny  []Rule
+}
+
+func (f Filter) Allows(name, model, backdrop string) bool {
+	for _, r := range f.Deny {
+		if r.Match(name, model, backdrop) {
+			return false
+		}
+	}
+	if len(f.Allow) == 0 {
+		return true
+	}
+	for _, r := range f.Allow {
+		if r.Match(name, model, backdrop) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package match
+
+import "testing"
+
+func TestPattern(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"Plush Pepe", "plush pepe", true},
+		{"Plush*", "Plush Pepe", true},
+		{"Plush*", "Jelly Bunny", false},
+		{"?at", "Cat", true},
+		{"?at", "Coat", false},
+		{"Black", "Black (1.5%)", true}, // the rarity suffix is ignored
+		{"/^(onyx|black)/", "Onyx Black", true},
+		{"/black$/", "Onyx Black (2%)", true},
+		{"/^black/", "Onyx Black", false},
+		{"/pepe/", "PLUSH PEPE", true},
+		{"", "Cat", false},
+	}
+	for _, tt := range tests {
+		p, err := Compile(tt.pattern)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", tt.pattern, err)
+		}
+		if got := p.Match(tt.s); got != tt.want {
+			t.Errorf("%q.Match(%q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+
+	var nilPattern *Pattern
+	if !nilPattern.Match("anything") {
+		t.Error("nil pattern does not match")
+	}
+	for _, invalid := range []string{"/(/", "[a"} {
+		if _, err := Compile(invalid); err == nil {
+			t.Errorf("Compile(%q): no error", invalid)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	rule := func(collection, model, backdrop string) Rule {
+		r, err := NewRule(collection, model, backdrop)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	f := Filter{
+		Allow: []Rule{rule("Cat*", "", ""), rule("", "Gold", "")},
+		Deny:  []Rule{rule("Cat Toy", "", ""), rule("", "", "/^white/")},
+	}
+	tests := []struct {
+		name, model, backdrop string
+		want                  bool
+	}{
+		{"Cat Hat", "Plain", "Black", true},
+		{"Dog", "Gold (1%)", "Black", true},
+		{"Dog", "Plain", "Black", false},    // no allow rule matches
+		{"Cat Toy", "Gold", "Black", false}, // deny wins over allow
+		{"Cat Hat", "Plain", "White Snow", false},
+	}
+	for _, tt := range tests {
+		if got := f.Allows(tt.name, tt.model, tt.backdrop); got != tt.want {
+			t.Errorf("Allows(%s, %s, %s) = %v, want %v", tt.name, tt.model, tt.backdrop, got, tt.want)
+		}
+	}
+	if !(Filter{}).Allows("Dog", "Plain", "Black") {
+		t.Error("empty filter rejects a gift")
+	}
+}
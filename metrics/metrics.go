@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "autobid"
+
+var (
+	ScanDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scan_duration_seconds",
+		Help:      "Duration of a scan cycle.",
+		Buckets:   []float64{1, 2.5, 5, 10, 20, 40, 80, 160, 320},
+	}, []string{"mode", "result"})
+
+	AuctionsFetched = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auctions_fetched_total",
+		Help:      "Auctions returned by Tonnel.",
+	})
+
+	AuctionsEligible = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auctions_eligible_total",
+		Help:      "Auctions left after the price, time and backdrop filters.",
+	})
+
+	ListingsFetched = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "listings_fetched_total",
+		Help:      "Listings returned per market.",
+	}, []string{"market"})
+
+	FloorLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "floor_lookups_total",
+		Help:      "Floor lookups per source.",
+	}, []string{"source", "result"})
+
+	FloorLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "floor_lookup_duration_seconds",
+		Help:      "Latency of floor lookups per source, cache hits included.",
+		Buckets:   []float64{.005, .025, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"source"})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups by result: hit, stale (served and refreshed) or miss.",
+	}, []string{"result"})
+
+	FloodWaits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "flood_waits_total",
+		Help:      "429 responses per origin and proxy.",
+	}, []string{"origin", "proxy"})
+
+	AlertsSent = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alerts_sent_total",
+		Help:      "Telegram messages delivered.",
+	})
+
+	AlertsFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alerts_failed_total",
+		Help:      "Telegram messages dropped, by reason.",
+	}, []string{"reason"})
+)
+
+func ObserveScan(mode string, d time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	ScanDuration.WithLabelValues(mode, result).Observe(d.Seconds())
+}
+
+// proxy is nil for direct connections, credentials are never exported
+func FloodWait(origin string, proxy *url.URL) {
+	FloodWaits.WithLabelValues(origin, ProxyLabel(proxy)).Inc()
+}
+
+func ProxyLabel(proxy *url.URL) string {
+	if proxy == nil {
+		return "direct"
+	}
+	return proxy.Host
+}
+
+// reports the value of fn on every scrape
+func Gauge(name, help string, fn func() float64) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, fn)
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
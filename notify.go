@@ -0,0 +1,170 @@
+package main
+
+import (
+	"autobid/config"
+	"autobid/history"
+	"autobid/paper"
+	"autobid/pricing"
+	"autobid/scanner"
+	"autobid/store"
+	"autobid/telegram"
+	"autobid/tonnel"
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var sourceLinks = map[string]string{
+	pricing.SourceTonnel:  "https://t.me/tonnel_network_bot/gifts?startapp=ref_438949837",
+	pricing.SourcePortals: scanner.PortalsURL,
+}
+
+const footer = "<b><a href=\"https://t.me/portals/market?startapp=7t5no1\">Portals</a></b> | <b><a href=\"https://t.me/tonnel_network_bot/gifts?startapp=ref_438949837\">Tonnel</a></b>"
+
+type telegramNotifier struct {
+	cfg   *config.Config
+	queue *telegram.Queue
+	sales *history.Store
+	db    *store.DB     // optional
+	paper *paper.Ledger // optional, virtually bids on every auction alert
+}
+
+func (n *telegramNotifier) Notify(ctx context.Context, o scanner.Opportunity) error {
+	msg := n.message(o)
+	if n.paper != nil {
+		n.paper.Bid(o)
+	}
+	button := "Place Bid"
+	mode := config.ModeAuctions
+	if o.Kind == scanner.KindListing {
+		button = fmt.Sprintf("Buy on %s", o.Market)
+		mode = config.ModeListings
+	}
+
+	if n.db != nil {
+		a := store.Alert{
+			SentAt:   time.Now(),
+			Mode:     mode,
+			Strategy: o.Strategy,
+			Market:   o.Market,
+			GiftID:   o.GiftID,
+			Name:     o.Name,
+			Num:      o.Num,
+			Price:    o.Price,
+			Floor:    o.Quote.Price,
+			Profit:   o.Profit.Ton,
+			Message:  msg,
+		}
+		if o.Kind == scanner.KindListing {
+			a.ListingID = o.ID
+		}
+		if err := n.db.RecordAlert(a); err != nil {
+			slog.Warn("recording alert failed", "gift_id", o.GiftID, "err", err)
+		}
+	}
+
+	return n.queue.Send(ctx, telegram.Message{
+		ChatID: o.Chat,
+		Text:   msg,
+		Markup: &telegram.InlineKeyboardMarkup{
+			InlineKeyboard: [][]telegram.InlineKeyboardButton{
+				{{Text: button, URL: o.BuyURL}},
+			},
+		},
+	})
+}
+
+func (n *telegramNotifier) message(o scanner.Opportunity) string {
+	link := fmt.Sprintf("https://t.me/nft/%s-%d", pricing.ShortName(o.Name), o.Num)
+	title := fmt.Sprintf("<a href=\"%s\">%s #%d</a>", link, o.Name, o.Num)
+	if o.Strategy != "" && o.Strategy != config.DefaultStrategy {
+		title += fmt.Sprintf(" [%s]", html.EscapeString(o.Strategy))
+	}
+	if o.Kind == scanner.KindListing {
+		return fmt.Sprintf("%s\n\nBuy on %s: <b>%f</b> TON\nSell on %s: <b>%f</b> TON (<b>%f</b> TON after fees)\n%s%s%sProfit: <b>%f</b>%% (%f TON)\n\n%s", title, o.Market, o.Price, o.SellMarket, o.Quote.Price, o.Profit.Floor, rarityLine(o), n.fairValueLine(o.Quote.Key), depthLine(o.Quote), o.Profit.Percentage*100, o.Profit.Ton, footer)
+	}
+
+	d := time.Until(o.EndsAt)
+	hours := int(d / time.Hour)
+	d -= time.Duration(hours) * time.Hour
+	minutes := int(d / time.Minute)
+	d -= time.Duration(minutes) * time.Minute
+	seconds := int(d / time.Second)
+
+	return fmt.Sprintf("%s\n\nBid Cost: <b>%f</b> TON\nMin Sell: <b>%f</b> TON\n%s%s%sProfit: <b>%f</b>%% (%f TON)\n%sEnd in: %02d:%02d:%02d\n\n%s", title, o.Price, o.Quote.Price, rarityLine(o), n.fairValueLine(o.Quote.Key), depthLine(o.Quote), o.Profit.Percentage*100, o.Profit.Ton, quoteLines(o.Others), hours, minutes, seconds, footer)
+}
+
+func (n *telegramNotifier) fairValueLine(key pricing.GiftKey) string {
+	since := time.Now().Add(-time.Duration(n.cfg.FairValueWindow * float64(time.Second)))
+	fv, ok := n.sales.FairValue(key, since, n.cfg.FairValueMinSales)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("Fair Value: <b>%f</b> TON (%d sales)\n", fv.Price, fv.Sales)
+}
+
+// per strategy summary of the paper ledger
+func pnlMessage(l *paper.Ledger) string {
+	summaries := l.Summaries()
+	if len(summaries) == 0 {
+		return "<b>Paper P&amp;L</b>\n\nNo paper bids yet."
+	}
+	msg := "<b>Paper P&amp;L</b>\n"
+	for _, s := range summaries {
+		msg += fmt.Sprintf("\n<b>%s</b>: %d won, %d lost, %d expired, %d open", html.EscapeString(s.Strategy), s.Won, s.Lost, s.Expired, s.Open)
+		if s.Won+s.Lost > 0 {
+			msg += fmt.Sprintf(" (hit rate %.1f%%)", s.HitRate*100)
+		}
+		msg += fmt.Sprintf("\nSpent: %f TON, marked at %f TON\nP&amp;L: <b>%+f</b> TON\nAt stake: %f TON\n", s.Spent, s.Value, s.PnL, s.AtStake)
+	}
+	return msg
+}
+
+type adminAlerter struct {
+	tgLogger *telegram.TGLogger
+}
+
+func (a *adminAlerter) Alert(ctx context.Context, text string) error {
+	return a.tgLogger.SendMessage(ctx, fmt.Sprintf("⚠️ %s", html.EscapeString(text)), true, nil, nil)
+}
+
+func quoteLines(quotes []pricing.Quote) string {
+	msg := ""
+	for _, q := range quotes {
+		msg += fmt.Sprintf("<a href=\"%s\">%s</a> Floor: <b>%f</b> TON\n", sourceLinks[q.Source], q.Source, q.Price)
+	}
+	return msg
+}
+
+// rarity of each trait that has one, and combined when all are known
+func rarityLine(o scanner.Opportunity) string {
+	traits := []tonnel.Trait{tonnel.ParseTrait(o.Model), tonnel.ParseTrait(o.Backdrop), tonnel.ParseTrait(o.Symbol)}
+	parts := []string{}
+	for i, label := range []string{"Model", "Backdrop", "Symbol"} {
+		if traits[i].Rarity > 0 {
+			parts = append(parts, fmt.Sprintf("%s %s%%", label, strconv.FormatFloat(traits[i].Rarity, 'f', -1, 64)))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	if combined := tonnel.CombinedRarity(traits...); combined > 0 {
+		parts = append(parts, fmt.Sprintf("Combined <b>%.4g%%</b>", combined))
+	}
+	return fmt.Sprintf("Rarity: %s\n", strings.Join(parts, ", "))
+}
+
+func depthLine(q pricing.Quote) string {
+	if q.Listings == 0 {
+		return ""
+	}
+	next := "none"
+	if q.Depth > 0 {
+		next = fmt.Sprintf("%f TON", q.Depth)
+	}
+	return fmt.Sprintf("Depth: <b>%d</b> listings, <b>%d</b> near floor (lowest %f TON, next %s)\nRecent Sales: <b>%d</b>\n", q.Listings, q.NearFloor, q.Lowest, next, q.RecentSales)
+}
@@ -0,0 +1,285 @@
+package paper
+
+import (
+	"autobid/pricing"
+	"autobid/scanner"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusOpen    Status = "open"
+	StatusWon     Status = "won"
+	StatusLost    Status = "lost"
+	StatusExpired Status = "expired" // the final bid never became known
+)
+
+// Position is a virtual bid on one auction by one strategy
+type Position struct {
+	ID        string          `json:"id"`
+	Strategy  string          `json:"strategy"`
+	GiftID    int             `json:"gift_id"`
+	Name      string          `json:"name"`
+	Num       int             `json:"num"`
+	Key       pricing.GiftKey `json:"key"` // floor the gift is marked at
+	Bid       float64         `json:"bid"`
+	Bids      int             `json:"bids"` // times the bid was raised by later alerts, plus one
+	Floor     float64         `json:"floor"`
+	OpenedAt  time.Time       `json:"opened_at"`
+	EndsAt    time.Time       `json:"ends_at"`
+	Status    Status          `json:"status"`
+	FinalBid  float64         `json:"final_bid,omitempty"` // highest real bid
+	SettledAt time.Time       `json:"settled_at,omitempty"`
+	Mark      float64         `json:"mark,omitempty"` // floor of a won gift once it was settled
+	MarkedAt  time.Time       `json:"marked_at,omitempty"`
+}
+
+// PnL is the unrealized profit of a won gift at its mark, 0 otherwise
+func (p Position) PnL() float64 {
+	if p.Status != StatusWon || p.Mark == 0 {
+		return 0
+	}
+	return p.Mark - p.Bid
+}
+
+type Summary struct {
+	Strategy string  `json:"strategy"`
+	Open     int     `json:"open"`
+	Won      int     `json:"won"`
+	Lost     int     `json:"lost"`
+	Expired  int     `json:"expired"`
+	AtStake  float64 `json:"at_stake"` // open bids
+	Spent    float64 `json:"spent"`    // won bids
+	Value    float64 `json:"value"`    // won gifts at their marks
+	PnL      float64 `json:"pnl"`
+	HitRate  float64 `json:"hit_rate"` // won / settled
+}
+
+type Store interface {
+	SavePosition(p Position) error
+	Positions() ([]Position, error)
+}
+
+// FinalBids reports the winning bid of an auction of giftID that ended
+// after endedAfter, false while it is unknown
+type FinalBids interface {
+	FinalBid(giftID int, endedAfter time.Time) (float64, bool)
+}
+
+type Options struct {
+	Store       Store // optional, positions are kept in memory only without it
+	FinalBids   FinalBids
+	Floor       pricing.PriceSource // marks won gifts
+	SettleAfter time.Duration       // how long after the end a final bid is waited for
+}
+
+// Ledger virtually places the bid of every auction alert and tracks the outcome
+type Ledger struct {
+	opt       *Options
+	mu        sync.Mutex
+	positions map[string]*Position
+}
+
+func New(opt *Options) (*Ledger, error) {
+	l := &Ledger{opt: opt, positions: map[string]*Position{}}
+	if opt.Store == nil {
+		return l, nil
+	}
+	positions, err := opt.Store.Positions()
+	if err != nil {
+		return nil, fmt.Errorf("loading positions: %w", err)
+	}
+	for i := range positions {
+		l.positions[positions[i].ID] = &positions[i]
+	}
+	return l, nil
+}
+
+// Bid opens a position for an auction alert, or raises the bid of the open
+// one as a real bidder would when outbid
+func (l *Ledger) Bid(o scanner.Opportunity) {
+	if o.Kind != scanner.KindAuction {
+		return
+	}
+	id := o.Strategy + ":" + strconv.Itoa(o.GiftID) + ":" + strconv.FormatInt(o.EndsAt.Unix(), 10)
+
+	l.mu.Lock()
+	p, ok := l.positions[id]
+	if ok && (p.Status != StatusOpen || o.Price <= p.Bid) {
+		l.mu.Unlock()
+		return
+	}
+	if !ok {
+		p = &Position{
+			ID:       id,
+			Strategy: o.Strategy,
+			GiftID:   o.GiftID,
+			Name:     o.Name,
+			Num:      o.Num,
+			Key:      o.Quote.Key,
+			OpenedAt: o.FoundAt,
+			EndsAt:   o.EndsAt,
+			Status:   StatusOpen,
+		}
+		l.positions[id] = p
+	}
+	p.Bid = o.Price
+	p.Floor = o.Quote.Price
+	p.Bids++
+	saved := *p
+	l.mu.Unlock()
+
+	slog.Info("paper bid placed", "strategy", saved.Strategy, "gift_id", saved.GiftID, "bid", saved.Bid, "bids", saved.Bids)
+	l.save(saved)
+}
+
+// Settle decides ended auctions and marks won gifts at their floor once,
+// marks that failed are retried on the next call
+func (l *Ledger) Settle(ctx context.Context, now time.Time) {
+	l.mu.Lock()
+	pending := []Position{}
+	for _, p := range l.positions {
+		if p.Status == StatusWon && p.MarkedAt.IsZero() || p.Status == StatusOpen && now.After(p.EndsAt) {
+			pending = append(pending, *p)
+		}
+	}
+	l.mu.Unlock()
+
+	for _, p := range pending {
+		seen := p
+		if p.Status == StatusOpen {
+			final, ok := l.opt.FinalBids.FinalBid(p.GiftID, p.EndsAt.Add(-time.Minute))
+			switch {
+			case ok && final < p.Bid:
+				p.Status = StatusWon
+			case ok:
+				p.Status = StatusLost
+			case now.After(p.EndsAt.Add(l.opt.SettleAfter)):
+				p.Status = StatusExpired
+			default:
+				continue
+			}
+			p.FinalBid = final
+			p.SettledAt = now
+		}
+		if p.Status == StatusWon {
+			q, err := l.opt.Floor.Floor(ctx, p.Key)
+			if err != nil {
+				slog.Warn("marking paper position failed", "gift_id", p.GiftID, "err", err)
+			} else {
+				p.Mark = q.Price
+				p.MarkedAt = now
+			}
+		}
+
+		// a bid raised or another Settle done meanwhile wins, the position is
+		// looked at again on the next call
+		l.mu.Lock()
+		cur := l.positions[p.ID]
+		if cur.Status != seen.Status || cur.Bids != seen.Bids || !cur.MarkedAt.Equal(seen.MarkedAt) {
+			l.mu.Unlock()
+			continue
+		}
+		l.positions[p.ID] = &p
+		l.mu.Unlock()
+		if seen.Status == StatusOpen {
+			slog.Info("paper position settled", "strategy", p.Strategy, "gift_id", p.GiftID, "status", p.Status, "bid", p.Bid, "final_bid", p.FinalBid)
+		}
+		l.save(p)
+	}
+}
+
+// Run settles every interval until ctx is done
+func (l *Ledger) Run(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		l.Settle(ctx, time.Now())
+	}
+}
+
+func (l *Ledger) save(p Position) {
+	if l.opt.Store == nil {
+		return
+	}
+	if err := l.opt.Store.SavePosition(p); err != nil {
+		slog.Warn("saving paper position failed", "id", p.ID, "err", err)
+	}
+}
+
+// Positions returns every position, newest first
+func (l *Ledger) Positions() []Position {
+	l.mu.Lock()
+	positions := make([]Position, 0, len(l.positions))
+	for _, p := range l.positions {
+		positions = append(positions, *p)
+	}
+	l.mu.Unlock()
+	sort.Slice(positions, func(i, j int) bool { return positions[i].OpenedAt.After(positions[j].OpenedAt) })
+	return positions
+}
+
+// Summaries returns the ledger per strategy, sorted by name
+func (l *Ledger) Summaries() []Summary {
+	byStrategy := map[string]*Summary{}
+	for _, p := range l.Positions() {
+		s, ok := byStrategy[p.Strategy]
+		if !ok {
+			s = &Summary{Strategy: p.Strategy}
+			byStrategy[p.Strategy] = s
+		}
+		switch p.Status {
+		case StatusOpen:
+			s.Open++
+			s.AtStake += p.Bid
+		case StatusWon:
+			s.Won++
+			s.Spent += p.Bid
+			s.Value += p.Mark
+			s.PnL += p.PnL()
+		case StatusLost:
+			s.Lost++
+		case StatusExpired:
+			s.Expired++
+		}
+	}
+
+	summaries := make([]Summary, 0, len(byStrategy))
+	for _, s := range byStrategy {
+		if settled := s.Won + s.Lost; settled > 0 {
+			s.HitRate = float64(s.Won) / float64(settled)
+		}
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Strategy < summaries[j].Strategy })
+	return summaries
+}
+
+type Export struct {
+	Summaries []Summary  `json:"summaries"`
+	Positions []Position `json:"positions"`
+}
+
+func (l *Ledger) Export() Export {
+	return Export{Summaries: l.Summaries(), Positions: l.Positions()}
+}
+
+// Handler serves the JSON export
+func (l *Ledger) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(l.Export())
+	})
+}
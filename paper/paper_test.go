@@ -0,0 +1,162 @@
+package paper
+
+import (
+	"autobid/pricing"
+	"autobid/scanner"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type finalBids map[int]float64
+
+func (f finalBids) FinalBid(giftID int, endedAfter time.Time) (float64, bool) {
+	bid, ok := f[giftID]
+	return bid, ok
+}
+
+// fails the first lookup
+type flakyFloor struct {
+	calls int
+}
+
+func (f *flakyFloor) Floor(ctx context.Context, key pricing.GiftKey) (pricing.Quote, error) {
+	f.calls++
+	if f.calls == 1 {
+		return pricing.Quote{}, errors.New("502: bad gateway")
+	}
+	return pricing.Quote{Key: key, Price: 20}, nil
+}
+
+// raises the bid of the position being settled
+type raisingFinalBids struct {
+	l *Ledger
+	o scanner.Opportunity
+}
+
+func (f raisingFinalBids) FinalBid(giftID int, endedAfter time.Time) (float64, bool) {
+	f.l.Bid(f.o)
+	return 12, true
+}
+
+type memStore map[string]Position
+
+func (s memStore) SavePosition(p Position) error {
+	s[p.ID] = p
+	return nil
+}
+
+func (s memStore) Positions() ([]Position, error) {
+	positions := []Position{}
+	for _, p := range s {
+		positions = append(positions, p)
+	}
+	return positions, nil
+}
+
+func alert(giftID int, bid float64, endsAt time.Time) scanner.Opportunity {
+	return scanner.Opportunity{
+		Kind:     scanner.KindAuction,
+		Strategy: "default",
+		GiftID:   giftID,
+		Name:     "Cat",
+		Price:    bid,
+		Quote:    pricing.Quote{Key: pricing.GiftKey{Name: "Cat"}, Price: 15},
+		EndsAt:   endsAt,
+		FoundAt:  endsAt.Add(-time.Hour),
+	}
+}
+
+func TestLedger(t *testing.T) {
+	now := time.Now()
+	ended, later := now.Add(-time.Minute), now.Add(time.Hour)
+	floor := &flakyFloor{}
+	store := memStore{}
+	l, err := New(&Options{
+		Store:       store,
+		FinalBids:   finalBids{1: 11, 2: 13},
+		Floor:       floor,
+		SettleAfter: 30 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.Bid(alert(1, 10, ended))
+	l.Bid(alert(1, 12, ended)) // outbid, raised
+	l.Bid(alert(1, 11, ended)) // lower, ignored
+	l.Bid(alert(2, 12, ended))
+	l.Bid(alert(3, 12, now.Add(-time.Hour)))
+	l.Bid(alert(4, 5, later))
+	l.Bid(scanner.Opportunity{Kind: scanner.KindListing, GiftID: 5, Price: 1})
+
+	// the first mark fails and is retried, a marked gift is not looked up again
+	for i := 0; i < 3; i++ {
+		l.Settle(context.Background(), now)
+	}
+	if floor.calls != 2 {
+		t.Errorf("floor looked up %d times, want 2", floor.calls)
+	}
+
+	want := map[int]struct {
+		status Status
+		bid    float64
+		bids   int
+		mark   float64
+	}{
+		1: {StatusWon, 12, 2, 20},
+		2: {StatusLost, 12, 1, 0},
+		3: {StatusExpired, 12, 1, 0},
+		4: {StatusOpen, 5, 1, 0},
+	}
+	positions := l.Positions()
+	if len(positions) != len(want) {
+		t.Fatalf("positions %+v, want %d", positions, len(want))
+	}
+	for _, p := range positions {
+		w := want[p.GiftID]
+		if p.Status != w.status || p.Bid != w.bid || p.Bids != w.bids || p.Mark != w.mark {
+			t.Errorf("gift %d: %+v, want %+v", p.GiftID, p, w)
+		}
+	}
+
+	s := l.Summaries()
+	wantSummary := Summary{Strategy: "default", Open: 1, Won: 1, Lost: 1, Expired: 1, AtStake: 5, Spent: 12, Value: 20, PnL: 8, HitRate: 0.5}
+	if len(s) != 1 || s[0] != wantSummary {
+		t.Errorf("summaries %+v, want %+v", s, wantSummary)
+	}
+
+	// positions survive a restart
+	reloaded, err := New(&Options{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.Summaries(); len(got) != 1 || got[0] != wantSummary {
+		t.Errorf("reloaded summaries %+v, want %+v", got, wantSummary)
+	}
+}
+
+// a bid raised while the final bid is looked up is not overwritten
+func TestSettleKeepsRaisedBid(t *testing.T) {
+	now := time.Now()
+	opt := &Options{Floor: &flakyFloor{}, SettleAfter: time.Hour}
+	l, err := New(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt.FinalBids = raisingFinalBids{l: l, o: alert(1, 11, now.Add(-time.Minute))}
+	l.Bid(alert(1, 10, now.Add(-time.Minute)))
+
+	l.Settle(context.Background(), now)
+	p := l.Positions()[0]
+	if p.Status != StatusOpen || p.Bid != 11 || p.Bids != 2 {
+		t.Errorf("status %s, bid %v after %d bids, want open at 11 after 2", p.Status, p.Bid, p.Bids)
+	}
+
+	// the next call settles the raised bid
+	l.Settle(context.Background(), now)
+	if p := l.Positions()[0]; p.Status != StatusLost || p.Bid != 11 {
+		t.Errorf("status %s, bid %v, want lost at 11", p.Status, p.Bid)
+	}
+}
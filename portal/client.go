@@ -1,12 +1,15 @@
 package portal
 
 import (
+	"autobid/logging"
+	"autobid/metrics"
 	"autobid/tlsclient"
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/url"
+	"strconv"
 	"time"
 )
 
@@ -18,6 +21,7 @@ type PortalAPI struct {
 type Options struct {
 	Proxies      []*url.URL
 	FloodRetries uint32
+	Auth         string
 }
 
 func New(opt *Options) (*PortalAPI, error) {
@@ -51,6 +55,38 @@ type CollectionFloorPrices struct {
 	Symbols   map[string]string `json:"symbols"`
 }
 
+type Attribute struct {
+	Type           string  `json:"type"`
+	Value          string  `json:"value"`
+	RarityPerMille float64 `json:"rarity_per_mille"`
+}
+
+type Listing struct {
+	ID                       string      `json:"id"`
+	TgID                     string      `json:"tg_id"`
+	CollectionID             string      `json:"collection_id"`
+	ExternalCollectionNumber int         `json:"external_collection_number"`
+	Name                     string      `json:"name"`
+	Price                    string      `json:"price"`
+	Status                   string      `json:"status"`
+	Attributes               []Attribute `json:"attributes"`
+	ListedAt                 time.Time   `json:"listed_at"`
+}
+
+type SearchResults struct {
+	Results []Listing `json:"results"`
+}
+
+// returns nil if listing has no attribute of this type
+func (l *Listing) Attribute(attrType string) *Attribute {
+	for i := range l.Attributes {
+		if l.Attributes[i].Type == attrType {
+			return &l.Attributes[i]
+		}
+	}
+	return nil
+}
+
 func (api *PortalAPI) GetFloor(ctx context.Context, giftName string) (*FloorPrices, error) {
 	url, err := tlsclient.SafeURL("https://portals-market.com/api/collections/filters", map[string]string{
 		"short_names": giftName,
@@ -59,11 +95,54 @@ func (api *PortalAPI) GetFloor(ctx context.Context, giftName string) (*FloorPric
 		return nil, err
 	}
 
-	headers := make(map[string]string, len(DEFAULT_HEADERS))
+	resp, err := api.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	var prices *FloorPrices
+	if err := json.Unmarshal(resp.Body, &prices); err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %v", err)
+	}
+
+	return prices, nil
+}
+
+func (api *PortalAPI) SearchListings(ctx context.Context, offset uint32, limit uint32) ([]Listing, error) {
+	url, err := tlsclient.SafeURL("https://portals-market.com/api/nfts/search", map[string]string{
+		"offset":          strconv.FormatUint(uint64(offset), 10),
+		"limit":           strconv.FormatUint(uint64(limit), 10),
+		"sort_by":         "listed_at desc",
+		"status":          "listed",
+		"exclude_bundled": "true",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := api.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	var results SearchResults
+	if err := json.Unmarshal(resp.Body, &results); err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %v", err)
+	}
+
+	return results.Results, nil
+}
+
+func (api *PortalAPI) get(ctx context.Context, url string) (*tlsclient.RequestResponse, error) {
+	headers := make(map[string]string, len(DEFAULT_HEADERS)+1)
 	for k, v := range DEFAULT_HEADERS {
 		headers[k] = v
 	}
+	if api.opt.Auth != "" {
+		headers["authorization"] = api.opt.Auth
+	}
 
+	var err error
 	var resp *tlsclient.RequestResponse
 	i := uint32(0)
 	t := FLOOD_WAIT
@@ -75,6 +154,7 @@ func (api *PortalAPI) GetFloor(ctx context.Context, giftName string) (*FloorPric
 
 		if !resp.Ok {
 			if resp.StatusCode == 429 {
+				metrics.FloodWait("portals-market.com", api.conn.Proxy())
 				i += 1
 				if i > api.opt.FloodRetries {
 					return nil, &tlsclient.FloodWaitError{
@@ -84,8 +164,12 @@ func (api *PortalAPI) GetFloor(ctx context.Context, giftName string) (*FloorPric
 					}
 				}
 
-				log.Printf("INFO: portals-market.com returned 429, waiting for %f secs...", t.Seconds())
-				time.Sleep(t)
+				slog.Warn("flood wait", "origin", "portals-market.com", logging.Proxy(api.conn.Proxy()), "duration", t)
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(t):
+				}
 				t *= 2
 				continue
 			}
@@ -96,10 +180,9 @@ func (api *PortalAPI) GetFloor(ctx context.Context, giftName string) (*FloorPric
 		break
 	}
 
-	var prices *FloorPrices
-	if err := json.Unmarshal(resp.Body, &prices); err != nil {
-		return nil, fmt.Errorf("error parsing JSON: %v", err)
-	}
+	return resp, nil
+}
 
-	return prices, nil
+func (api *PortalAPI) Close() error {
+	return api.conn.Close()
 }
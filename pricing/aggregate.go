@@ -0,0 +1,134 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Policy func(quotes []Quote) float64
+
+const (
+	PolicyMin      = "min"
+	PolicyMedian   = "median"
+	PolicyWeighted = "weighted"
+)
+
+func PolicyByName(name string) (Policy, error) {
+	switch name {
+	case PolicyMin, "":
+		return Min, nil
+	case PolicyMedian:
+		return Median, nil
+	case PolicyWeighted:
+		return WeightedByLiquidity, nil
+	}
+	return nil, fmt.Errorf("unknown floor policy %q", name)
+}
+
+func Min(quotes []Quote) float64 {
+	min := quotes[0].Price
+	for _, q := range quotes[1:] {
+		if q.Price < min {
+			min = q.Price
+		}
+	}
+	return min
+}
+
+func Median(quotes []Quote) float64 {
+	prices := make([]float64, len(quotes))
+	for i, q := range quotes {
+		prices[i] = q.Price
+	}
+	sort.Float64s(prices)
+
+	mid := len(prices) / 2
+	if len(prices)%2 == 0 {
+		return (prices[mid-1] + prices[mid]) / 2
+	}
+	return prices[mid]
+}
+
+// sources with unknown liquidity only count when no source knows it
+func WeightedByLiquidity(quotes []Quote) float64 {
+	var sum, weights float64
+	for _, q := range quotes {
+		sum += q.Price * float64(q.Listings)
+		weights += float64(q.Listings)
+	}
+	if weights == 0 {
+		for _, q := range quotes {
+			sum += q.Price
+		}
+		return sum / float64(len(quotes))
+	}
+	return sum / weights
+}
+
+type Aggregate struct {
+	Sources map[string]PriceSource
+	Policy  Policy
+}
+
+func (a *Aggregate) Floor(ctx context.Context, key GiftKey) (Quote, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	quotes := []Quote{}
+	errs := []error{}
+	for _, src := range a.Sources {
+		wg.Add(1)
+		go func(src PriceSource) {
+			defer wg.Done()
+			q, err := src.Floor(ctx, key)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			quotes = append(quotes, q)
+		}(src)
+	}
+	wg.Wait()
+
+	if len(quotes) == 0 {
+		if len(errs) == 0 {
+			return Quote{}, fmt.Errorf("no price sources")
+		}
+		return Quote{}, errors.Join(errs...)
+	}
+	sort.Slice(quotes, func(i, j int) bool { return quotes[i].Source < quotes[j].Source })
+
+	names := make([]string, len(quotes))
+	listings, nearFloor, recentSales := 0, 0, 0
+	lowest := quotes[0].Lowest
+	depth := 0.0
+	for i, q := range quotes {
+		names[i] = q.Source
+		listings += q.Listings
+		nearFloor += q.NearFloor
+		recentSales += q.RecentSales
+		lowest = min(lowest, q.Lowest)
+		if q.Depth > 0 && (depth == 0 || q.Depth < depth) {
+			depth = q.Depth
+		}
+	}
+	return Quote{
+		Source:      strings.Join(names, "+"),
+		Key:         key,
+		Price:       a.Policy(quotes),
+		Lowest:      lowest,
+		Depth:       depth,
+		Listings:    listings,
+		NearFloor:   nearFloor,
+		RecentSales: recentSales,
+		Time:        time.Now(),
+		Parts:       quotes,
+	}, nil
+}
@@ -0,0 +1,82 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type quoteSource struct {
+	quote Quote
+	err   error
+}
+
+func (s quoteSource) Floor(ctx context.Context, key GiftKey) (Quote, error) {
+	q := s.quote
+	q.Key = key
+	return q, s.err
+}
+
+func TestPolicies(t *testing.T) {
+	quotes := []Quote{{Price: 10, Listings: 1}, {Price: 14, Listings: 3}, {Price: 12}}
+	tests := []struct {
+		name   string
+		quotes []Quote
+		want   float64
+	}{
+		{PolicyMin, quotes, 10},
+		{"", quotes, 10},
+		{PolicyMedian, quotes, 12},
+		{PolicyMedian, quotes[:2], 12},
+		{PolicyWeighted, quotes, 13},
+		// no source knows its listings, a plain mean
+		{PolicyWeighted, []Quote{{Price: 10}, {Price: 12}}, 11},
+	}
+	for _, tt := range tests {
+		policy, err := PolicyByName(tt.name)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.name, err)
+		}
+		if got := policy(tt.quotes); got != tt.want {
+			t.Errorf("%q of %v = %v, want %v", tt.name, tt.quotes, got, tt.want)
+		}
+	}
+	if _, err := PolicyByName("max"); err == nil {
+		t.Error("PolicyByName(max): no error")
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	key := GiftKey{Name: "Cat", Model: "Gold"}
+	a := &Aggregate{
+		Sources: map[string]PriceSource{
+			SourceTonnel:  quoteSource{quote: Quote{Source: SourceTonnel, Price: 12, Lowest: 11, Depth: 13, Listings: 4, NearFloor: 2, RecentSales: 5}},
+			SourcePortals: quoteSource{quote: Quote{Source: SourcePortals, Price: 10, Lowest: 10, Depth: 14, Listings: 2, NearFloor: 1}},
+			"broken":      quoteSource{err: errors.New("502: bad gateway")},
+		},
+		Policy: Min,
+	}
+	q, err := a.Floor(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Quote{Source: SourcePortals + "+" + SourceTonnel, Key: key, Price: 10, Lowest: 10, Depth: 13, Listings: 6, NearFloor: 3, RecentSales: 5}
+	if q.Source != want.Source || q.Key != want.Key || q.Price != want.Price || q.Lowest != want.Lowest || q.Depth != want.Depth ||
+		q.Listings != want.Listings || q.NearFloor != want.NearFloor || q.RecentSales != want.RecentSales {
+		t.Errorf("Floor = %+v, want %+v", q, want)
+	}
+	if len(q.Parts) != 2 || q.Parts[0].Source != SourcePortals || q.Parts[1].Source != SourceTonnel {
+		t.Errorf("parts %+v, want portals and tonnel in order", q.Parts)
+	}
+
+	// every source failed, the errors are kept
+	a.Sources = map[string]PriceSource{
+		SourceTonnel: quoteSource{err: &NoFloorError{Source: SourceTonnel, Key: key}},
+		"broken":     quoteSource{err: errors.New("502: bad gateway")},
+	}
+	_, err = a.Floor(context.Background(), key)
+	var noFloor *NoFloorError
+	if !errors.As(err, &noFloor) {
+		t.Errorf("Floor error %v, want a NoFloorError among them", err)
+	}
+}
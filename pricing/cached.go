@@ -0,0 +1,39 @@
+package pricing
+
+import (
+	"autobid/cache"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type CachedSource struct {
+	name   string
+	source PriceSource
+	cache  *cache.Cache
+	ttl    time.Duration
+	stale  time.Duration
+}
+
+func Cached(name string, source PriceSource, c *cache.Cache, ttl, stale time.Duration) *CachedSource {
+	return &CachedSource{name: name, source: source, cache: c, ttl: ttl, stale: stale}
+}
+
+func (s *CachedSource) Floor(ctx context.Context, key GiftKey) (Quote, error) {
+	raw, err := s.cache.Fetch(ctx, "floor:"+s.name+":"+key.ID(), s.ttl, s.stale, func(ctx context.Context) ([]byte, error) {
+		q, err := s.source.Floor(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(q)
+	})
+	if err != nil {
+		return Quote{}, err
+	}
+
+	var q Quote
+	if err := json.Unmarshal(raw, &q); err != nil {
+		return Quote{}, err
+	}
+	return q, nil
+}
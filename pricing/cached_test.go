@@ -0,0 +1,41 @@
+package pricing
+
+import (
+	"autobid/cache"
+	"context"
+	"testing"
+	"time"
+)
+
+type keySource map[GiftKey]float64
+
+func (s keySource) Floor(ctx context.Context, key GiftKey) (Quote, error) {
+	price, ok := s[key]
+	if !ok {
+		return Quote{}, &NoFloorError{Source: "test", Key: key}
+	}
+	return Quote{Source: "test", Key: key, Price: price}, nil
+}
+
+// a model and a backdrop with the same name print the same key
+func TestCachedKeysDoNotCollide(t *testing.T) {
+	model := GiftKey{Name: "Cat", Model: "Black"}
+	backdrop := GiftKey{Name: "Cat", Backdrop: "Black"}
+	if model.String() != backdrop.String() {
+		t.Fatalf("test assumes equal labels, got %q and %q", model, backdrop)
+	}
+
+	src := Cached("test", keySource{model: 10, backdrop: 20}, cache.New(cache.NewLRU(10)), time.Minute, 0)
+	for _, tt := range []struct {
+		key  GiftKey
+		want float64
+	}{{model, 10}, {backdrop, 20}, {model, 10}} {
+		q, err := src.Floor(context.Background(), tt.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.Price != tt.want {
+			t.Errorf("Floor(%+v) = %v, want %v", tt.key, q.Price, tt.want)
+		}
+	}
+}
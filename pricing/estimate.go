@@ -0,0 +1,93 @@
+package pricing
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// prices are sorted ascending and never empty, 0 means too few listings
+type Estimator func(prices []float64) float64
+
+func Lowest(prices []float64) float64 {
+	return prices[0]
+}
+
+// falls back to the most expensive listing when there are fewer than n
+func NthLowest(n int) Estimator {
+	return func(prices []float64) float64 {
+		return prices[min(n, len(prices))-1]
+	}
+}
+
+// drops fraction of the listings from each end before averaging
+func TrimmedMean(fraction float64) Estimator {
+	return func(prices []float64) float64 {
+		trim := int(math.Floor(float64(len(prices)) * fraction))
+		kept := prices[trim : len(prices)-trim]
+		if len(kept) == 0 {
+			kept = prices
+		}
+
+		sum := 0.0
+		for _, p := range kept {
+			sum += p
+		}
+		return sum / float64(len(kept))
+	}
+}
+
+// price of the cheapest listing left once units listings have been bought, 0
+// when nothing would be left
+func AbsorbDepth(units int) Estimator {
+	return func(prices []float64) float64 {
+		if len(prices) <= units {
+			return 0
+		}
+		return prices[units]
+	}
+}
+
+const (
+	EstimatorLowest      = "lowest"
+	EstimatorNth         = "nth"
+	EstimatorTrimmedMean = "trimmed_mean"
+	EstimatorDepth       = "depth"
+)
+
+// parses "name" or "name:param", e.g. "nth:3" or "trimmed_mean:0.2"
+func EstimatorByName(spec string) (Estimator, error) {
+	name, param, _ := strings.Cut(spec, ":")
+	switch name {
+	case EstimatorLowest, "":
+		return Lowest, nil
+	case EstimatorNth, EstimatorDepth:
+		n := 1
+		if name == EstimatorNth {
+			n = 2
+		}
+		if param != "" {
+			var err error
+			n, err = strconv.Atoi(param)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid %s estimator parameter %q", name, param)
+			}
+		}
+		if name == EstimatorDepth {
+			return AbsorbDepth(n), nil
+		}
+		return NthLowest(n), nil
+	case EstimatorTrimmedMean:
+		fraction := 0.2
+		if param != "" {
+			var err error
+			fraction, err = strconv.ParseFloat(param, 64)
+			if err != nil || fraction < 0 || fraction >= 0.5 {
+				return nil, fmt.Errorf("invalid %s estimator parameter %q", name, param)
+			}
+		}
+		return TrimmedMean(fraction), nil
+	}
+	return nil, fmt.Errorf("unknown floor estimator %q", name)
+}
@@ -0,0 +1,42 @@
+package pricing
+
+import "testing"
+
+func TestEstimators(t *testing.T) {
+	prices := []float64{1, 2, 3, 4, 10}
+	tests := []struct {
+		spec   string
+		prices []float64
+		want   float64
+	}{
+		{"lowest", prices, 1},
+		{"", prices, 1},
+		{"nth", prices, 2},
+		{"nth:4", prices, 4},
+		{"nth:9", prices, 10}, // fewer listings, the priciest
+		{"trimmed_mean", prices, 3},
+		{"trimmed_mean:0", prices, 4},
+		{"trimmed_mean:0.4", []float64{5}, 5}, // nothing left after trimming
+		{"depth", prices, 2},
+		{"depth:3", prices, 4},
+		{"depth", []float64{7}, 0}, // nothing left once one is bought
+		{"depth:5", prices, 0},
+	}
+	for _, tt := range tests {
+		e, err := EstimatorByName(tt.spec)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.spec, err)
+		}
+		if got := e(tt.prices); got != tt.want {
+			t.Errorf("%q of %v = %v, want %v", tt.spec, tt.prices, got, tt.want)
+		}
+	}
+}
+
+func TestEstimatorByNameErrors(t *testing.T) {
+	for _, spec := range []string{"median", "nth:0", "nth:x", "depth:-1", "trimmed_mean:0.5", "trimmed_mean:-0.1"} {
+		if _, err := EstimatorByName(spec); err == nil {
+			t.Errorf("%q: no error", spec)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package pricing
+
+import (
+	"autobid/metrics"
+	"autobid/tracing"
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentedSource counts and traces floor lookups and their latency under name
+type InstrumentedSource struct {
+	name   string
+	source PriceSource
+}
+
+func Instrumented(name string, source PriceSource) *InstrumentedSource {
+	return &InstrumentedSource{name: name, source: source}
+}
+
+func (s *InstrumentedSource) Floor(ctx context.Context, key GiftKey) (Quote, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "floor "+s.name, trace.WithAttributes(attribute.String("key", key.String())))
+	start := time.Now()
+	q, err := s.source.Floor(ctx, key)
+	span.SetAttributes(attribute.Float64("price", q.Price))
+	tracing.End(span, err)
+	metrics.FloorLatency.WithLabelValues(s.name).Observe(time.Since(start).Seconds())
+
+	result := "ok"
+	var noFloor *NoFloorError
+	if errors.As(err, &noFloor) {
+		result = "no_floor"
+	} else if err != nil {
+		result = "error"
+	}
+	metrics.FloorLookups.WithLabelValues(s.name, result).Inc()
+	return q, err
+}
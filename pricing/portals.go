@@ -0,0 +1,125 @@
+package pricing
+
+import (
+	"autobid/cache"
+	"autobid/portal"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const SourcePortals = "Portals"
+
+type PortalsOptions struct {
+	Proxies []*url.URL
+	Auth    string
+	Cache   *cache.Cache // optional, caches whole collection floors
+	TTL     time.Duration
+	Stale   time.Duration
+}
+
+type PortalsSource struct {
+	opt *PortalsOptions
+}
+
+func NewPortalsSource(opt *PortalsOptions) *PortalsSource {
+	if opt == nil {
+		opt = &PortalsOptions{}
+	}
+	return &PortalsSource{opt: opt}
+}
+
+// portals has no combined filters, model floor wins over backdrop floor. The
+// collection floor is the cheapest model floor, every gift has a model.
+func (s *PortalsSource) Floor(ctx context.Context, key GiftKey) (Quote, error) {
+	giftName := ShortName(key.Name)
+	prices, err := s.collectionFloors(ctx, giftName)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	collection := prices.FloorPrices[giftName]
+	var floorStr string
+	var ok bool
+	switch {
+	case key.Model != "":
+		key.Backdrop = ""
+		floorStr, ok = collection.Models[StripRarity(key.Model)]
+	case key.Backdrop != "":
+		floorStr, ok = collection.Backdrops[StripRarity(key.Backdrop)]
+	default:
+		floorStr, ok = cheapest(collection.Models)
+	}
+	if !ok {
+		return Quote{}, &NoFloorError{Source: SourcePortals, Key: key}
+	}
+
+	floor, err := strconv.ParseFloat(floorStr, 64)
+	if err != nil {
+		return Quote{}, fmt.Errorf("invalid floor for \"%s\": %v", key, err)
+	}
+
+	return Quote{
+		Source: SourcePortals,
+		Key:    key,
+		Price:  floor,
+		Lowest: floor,
+		Time:   time.Now(),
+	}, nil
+}
+
+// the lowest of the floors that parse
+func cheapest(floors map[string]string) (string, bool) {
+	var found string
+	lowest := 0.0
+	for _, f := range floors {
+		price, err := strconv.ParseFloat(f, 64)
+		if err != nil || price <= 0 {
+			continue
+		}
+		if found == "" || price < lowest {
+			found, lowest = f, price
+		}
+	}
+	return found, found != ""
+}
+
+func (s *PortalsSource) collectionFloors(ctx context.Context, giftName string) (*portal.FloorPrices, error) {
+	if s.opt.Cache == nil {
+		return s.fetchCollectionFloors(ctx, giftName)
+	}
+
+	raw, err := s.opt.Cache.Fetch(ctx, "portals:filters:"+giftName, s.opt.TTL, s.opt.Stale, func(ctx context.Context) ([]byte, error) {
+		prices, err := s.fetchCollectionFloors(ctx, giftName)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(prices)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var prices portal.FloorPrices
+	if err := json.Unmarshal(raw, &prices); err != nil {
+		return nil, err
+	}
+	return &prices, nil
+}
+
+func (s *PortalsSource) fetchCollectionFloors(ctx context.Context, giftName string) (*portal.FloorPrices, error) {
+	client, err := portal.New(&portal.Options{
+		FloodRetries: 1,
+		Proxies:      s.opt.Proxies,
+		Auth:         s.opt.Auth,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	return client.GetFloor(ctx, giftName)
+}
@@ -0,0 +1,22 @@
+package pricing
+
+import "testing"
+
+func TestCheapest(t *testing.T) {
+	tests := []struct {
+		floors map[string]string
+		want   string
+		ok     bool
+	}{
+		{map[string]string{"Gold": "12.5", "Plain": "3", "Onyx": "40"}, "3", true},
+		{map[string]string{"Gold": "12.5", "Broken": "n/a", "Free": "0"}, "12.5", true},
+		{map[string]string{"Broken": "n/a"}, "", false},
+		{nil, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := cheapest(tt.floors)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("cheapest(%v) = %q, %t, want %q, %t", tt.floors, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package pricing
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// traits are named the way tonnel lists them, including the rarity suffix,
+// e.g. "Onyx Black (2%)"; empty trait means any
+type GiftKey struct {
+	Name     string
+	Model    string
+	Backdrop string
+}
+
+// String is for display, model only and backdrop only keys look alike
+func (k GiftKey) String() string {
+	parts := []string{k.Name}
+	if k.Model != "" {
+		parts = append(parts, k.Model)
+	}
+	if k.Backdrop != "" {
+		parts = append(parts, k.Backdrop)
+	}
+	return strings.Join(parts, " / ")
+}
+
+// ID tells every key apart, for cache and storage keys
+func (k GiftKey) ID() string {
+	return fmt.Sprintf("%s|%s|%s", k.Name, k.Model, k.Backdrop)
+}
+
+type Quote struct {
+	Source   string
+	Key      GiftKey // key the price was found for, may be wider than requested
+	Price    float64 // estimated floor
+	Lowest   float64 // cheapest listing
+	Depth    float64 // cheapest listing once one unit has been bought, 0 if unknown or only one is listed
+	Listings int     // 0 if unknown
+	// liquidity, 0 if unknown
+	NearFloor   int // listings priced within a band above the floor
+	RecentSales int // auctions settled recently
+	Time        time.Time
+	Parts       []Quote // quotes an aggregated quote was built from
+}
+
+type PriceSource interface {
+	Floor(ctx context.Context, key GiftKey) (Quote, error)
+}
+
+type NoFloorError struct {
+	Source string
+	Key    GiftKey
+}
+
+func (e *NoFloorError) Error() string {
+	return fmt.Sprintf("%s: no floor for \"%s\"", e.Source, e.Key)
+}
+
+var nonWordRe = regexp.MustCompile(`[\s\W]+`)
+var rarityRe = regexp.MustCompile(`\s*\([^)]*%?\)`)
+
+func ShortName(s string) string {
+	return strings.ToLower(nonWordRe.ReplaceAllString(s, ""))
+}
+
+func StripRarity(trait string) string {
+	return rarityRe.ReplaceAllString(trait, "")
+}
@@ -0,0 +1,134 @@
+package pricing
+
+import (
+	"autobid/cache"
+	"autobid/tonnel"
+	"context"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const SourceTonnel = "Tonnel"
+
+type TonnelOptions struct {
+	Proxies     []*url.URL
+	Estimator   Estimator     // defaults to Lowest
+	Band        float64       // listings up to floor*(1+Band) count as near the floor
+	SalesWindow time.Duration // 0 disables counting recent sales
+	Cache       *cache.Cache  // optional, caches recent sales counts
+	SalesTTL    time.Duration
+}
+
+type TonnelSource struct {
+	opt *TonnelOptions
+}
+
+func NewTonnelSource(opt *TonnelOptions) *TonnelSource {
+	if opt == nil {
+		opt = &TonnelOptions{}
+	}
+	if opt.Estimator == nil {
+		opt.Estimator = Lowest
+	}
+	return &TonnelSource{opt: opt}
+}
+
+// falls back to the collection floor when nothing is listed for the traits
+func (s *TonnelSource) Floor(ctx context.Context, key GiftKey) (Quote, error) {
+	client, err := tonnel.New(&tonnel.Options{
+		Proxies: s.opt.Proxies,
+	})
+	if err != nil {
+		return Quote{}, err
+	}
+	defer client.Close()
+
+	gifts, err := client.SearchListings(ctx, key.Name, key.Model, key.Backdrop, 30)
+	if err != nil {
+		return Quote{}, err
+	}
+	if len(gifts) == 0 && (key.Model != "" || key.Backdrop != "") {
+		key = GiftKey{Name: key.Name}
+		gifts, err = client.SearchListings(ctx, key.Name, "", "", 30)
+		if err != nil {
+			return Quote{}, err
+		}
+	}
+	if len(gifts) == 0 {
+		return Quote{}, &NoFloorError{Source: SourceTonnel, Key: key}
+	}
+
+	prices := make([]float64, len(gifts))
+	for i, g := range gifts {
+		prices[i] = g.Price
+	}
+	sort.Float64s(prices)
+	price := s.opt.Estimator(prices)
+	if price <= 0 {
+		// too thin for the estimator, e.g. depth:N with N or fewer listings
+		return Quote{}, &NoFloorError{Source: SourceTonnel, Key: key}
+	}
+
+	q := Quote{
+		Source:   SourceTonnel,
+		Key:      key,
+		Price:    price,
+		Lowest:   prices[0],
+		Depth:    AbsorbDepth(1)(prices),
+		Listings: len(prices),
+		Time:     time.Now(),
+	}
+	for _, p := range prices {
+		if p <= q.Price*(1+s.opt.Band) {
+			q.NearFloor++
+		}
+	}
+
+	if s.opt.SalesWindow > 0 {
+		// only informational, a failed count does not fail the floor
+		n, err := s.recentSales(ctx, client, key)
+		if err != nil {
+			slog.Warn("counting recent sales failed", "source", SourceTonnel, "key", key.String(), "err", err)
+		}
+		q.RecentSales = n
+	}
+
+	return q, nil
+}
+
+// auctions of key that settled within the sales window
+func (s *TonnelSource) recentSales(ctx context.Context, client *tonnel.TonnelAPI, key GiftKey) (int, error) {
+	count := func(ctx context.Context) (int, error) {
+		auctions, err := client.GetFinishedAuctions(ctx, key.Name, key.Model, key.Backdrop, 30)
+		if err != nil {
+			return 0, err
+		}
+		n := 0
+		since := time.Now().Add(-s.opt.SalesWindow)
+		for _, g := range auctions {
+			if g.FinalBid() > 0 && g.Auction.AuctionEndTime.After(since) {
+				n++
+			}
+		}
+		return n, nil
+	}
+	if s.opt.Cache == nil {
+		return count(ctx)
+	}
+
+	// no stale window, the client is closed once Floor returns
+	raw, err := s.opt.Cache.Fetch(ctx, "sales:"+SourceTonnel+":"+key.ID(), s.opt.SalesTTL, 0, func(ctx context.Context) ([]byte, error) {
+		n, err := count(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strconv.Itoa(n)), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(raw))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"autobid/ip"
+	"context"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// checks every proxy the way the scanner does at startup, without exiting on the first failure
+func runProxiesCheck(args []string) {
+	fs := flag.NewFlagSet("proxies check", flag.ExitOnError)
+	cfg, _ := mustLoadConfig(fs, args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	proxies := mustParseProxies(cfg)
+	if len(proxies) == 0 {
+		proxies = []*url.URL{nil}
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "proxy\tip\tlatency\terror")
+	for _, proxy := range proxies {
+		name := "direct"
+		list := []*url.URL{}
+		if proxy != nil {
+			name = proxy.Redacted()
+			list = append(list, proxy)
+		}
+
+		start := time.Now()
+		addr, err := checkProxy(ctx, list)
+		if err != nil {
+			failed++
+			msg := err.Error()
+			if proxy != nil && proxy.User != nil {
+				// dial errors may repeat the proxy url with its credentials
+				msg = strings.ReplaceAll(msg, proxy.User.String(), "xxxxx")
+			}
+			fmt.Fprintf(w, "%s\t-\t-\t%s\n", name, msg)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t\n", name, addr, time.Since(start).Round(time.Millisecond))
+	}
+	w.Flush()
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func checkProxy(ctx context.Context, proxies []*url.URL) (string, error) {
+	client, err := ip.New(&ip.Options{Proxies: proxies})
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+	return client.GetIp(ctx)
+}
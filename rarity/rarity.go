@@ -0,0 +1,101 @@
+package rarity
+
+import (
+	"autobid/match"
+	"autobid/pricing"
+	"autobid/tonnel"
+	"fmt"
+)
+
+// floor dimensions a rule compares a gift against
+const (
+	FloorModel         = "model"
+	FloorBackdrop      = "backdrop"
+	FloorModelBackdrop = "model_backdrop"
+	FloorCollection    = "collection"
+)
+
+// Rule matches gifts whose traits match all set patterns and are at most as
+// common as the set percentages, Floor is the floor they are compared with
+type Rule struct {
+	Model       *match.Pattern
+	Backdrop    *match.Pattern
+	Symbol      *match.Pattern
+	MaxModel    float64 // percent, 0 means any
+	MaxBackdrop float64
+	MaxSymbol   float64
+	Floor       string
+}
+
+func (r Rule) Match(model, backdrop, symbol string) bool {
+	return r.Model.Match(model) && r.Backdrop.Match(backdrop) && r.Symbol.Match(symbol) &&
+		within(model, r.MaxModel) && within(backdrop, r.MaxBackdrop) && within(symbol, r.MaxSymbol)
+}
+
+// traits without a known rarity never pass a limit
+func within(trait string, max float64) bool {
+	if max <= 0 {
+		return true
+	}
+	t := tonnel.ParseTrait(trait)
+	return t.Rarity > 0 && t.Rarity <= max
+}
+
+// Rules are tried in order, the first match decides the floor key
+type Rules []Rule
+
+// KeyFor returns the floor key gifts with these traits are compared with, the
+// model floor when no rule matches
+func (rs Rules) KeyFor(name, model, backdrop, symbol string) pricing.GiftKey {
+	floor := FloorModel
+	for _, r := range rs {
+		if r.Match(model, backdrop, symbol) {
+			floor = r.Floor
+			break
+		}
+	}
+	switch floor {
+	case FloorBackdrop:
+		return pricing.GiftKey{Name: name, Backdrop: backdrop}
+	case FloorModelBackdrop:
+		return pricing.GiftKey{Name: name, Model: model, Backdrop: backdrop}
+	case FloorCollection:
+		return pricing.GiftKey{Name: name}
+	default:
+		return pricing.GiftKey{Name: name, Model: model}
+	}
+}
+
+// NewRule compiles the non-empty patterns, an empty floor compares with the
+// backdrop floor
+func NewRule(model, backdrop, symbol, floor string) (Rule, error) {
+	r := Rule{Floor: floor}
+	if r.Floor == "" {
+		r.Floor = FloorBackdrop
+	}
+	if !IsFloor(r.Floor) {
+		return Rule{}, fmt.Errorf("unknown floor %q, expected %q, %q, %q or %q", floor, FloorModel, FloorBackdrop, FloorModelBackdrop, FloorCollection)
+	}
+	for _, f := range []struct {
+		s string
+		p **match.Pattern
+	}{{model, &r.Model}, {backdrop, &r.Backdrop}, {symbol, &r.Symbol}} {
+		if f.s == "" {
+			continue
+		}
+		p, err := match.Compile(f.s)
+		if err != nil {
+			return Rule{}, err
+		}
+		*f.p = p
+	}
+	return r, nil
+}
+
+func IsFloor(s string) bool {
+	switch s {
+	case FloorModel, FloorBackdrop, FloorModelBackdrop, FloorCollection:
+		return true
+	}
+	return false
+}
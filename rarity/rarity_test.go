@@ -0,0 +1,41 @@
+package rarity
+
+import (
+	"autobid/pricing"
+	"testing"
+)
+
+func TestKeyFor(t *testing.T) {
+	rule := func(model, backdrop, symbol, floor string, maxModel float64) Rule {
+		r, err := NewRule(model, backdrop, symbol, floor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.MaxModel = maxModel
+		return r
+	}
+	rules := Rules{
+		rule("", "", "Star", FloorModelBackdrop, 0),
+		rule("", "", "", FloorCollection, 1), // models of at most 1%
+		rule("", "Black", "", "", 0),
+	}
+	tests := []struct {
+		model, backdrop, symbol string
+		want                    pricing.GiftKey
+	}{
+		{"Plain (5%)", "Blue", "Star", pricing.GiftKey{Name: "Cat", Model: "Plain (5%)", Backdrop: "Blue"}},
+		{"Gold (0.5%)", "Black", "Moon", pricing.GiftKey{Name: "Cat"}}, // the first match wins
+		{"Gold (1.5%)", "Black", "Moon", pricing.GiftKey{Name: "Cat", Backdrop: "Black"}},
+		{"Gold", "Blue", "Moon", pricing.GiftKey{Name: "Cat", Model: "Gold"}}, // unknown rarity never passes a limit
+		{"Plain (5%)", "Blue", "Moon", pricing.GiftKey{Name: "Cat", Model: "Plain (5%)"}},
+	}
+	for _, tt := range tests {
+		if got := rules.KeyFor("Cat", tt.model, tt.backdrop, tt.symbol); got != tt.want {
+			t.Errorf("KeyFor(%s, %s, %s) = %+v, want %+v", tt.model, tt.backdrop, tt.symbol, got, tt.want)
+		}
+	}
+
+	if _, err := NewRule("Gold", "", "", "cheapest"); err == nil {
+		t.Error("NewRule with an unknown floor: no error")
+	}
+}
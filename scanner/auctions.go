@@ -0,0 +1,184 @@
+package scanner
+
+import (
+	"autobid/metrics"
+	"autobid/pricing"
+	"autobid/tonnel"
+	"autobid/tracing"
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type auctionJob struct {
+	gift  tonnel.Gift
+	group strategyGroup
+}
+
+type giftWithFloor struct {
+	gift       tonnel.Gift
+	key        pricing.GiftKey
+	strategies []*Strategy // the gift is eligible for
+	quote      pricing.Quote
+	err        error
+	span       trace.Span // ended once the gift is evaluated
+}
+
+// returns when the latest eligible auction ends, the earliest time worth scanning again
+func (e *Engine) ScanAuctions(ctx context.Context) (time.Time, error) {
+	slog.Debug("fetching auctions")
+	r := e.rules.Load()
+	gifts, err := e.opt.Auctions.Auctions(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	metrics.AuctionsFetched.Add(float64(len(gifts)))
+
+	var latest time.Time
+	filteredGifts := []tonnel.Gift{}
+	jobs := []auctionJob{}
+	for _, g := range gifts {
+		now := e.opt.Clock.Now()
+		groups := groupStrategies(r.Strategies, g.Name, g.Model, g.Backdrop, g.Symbol, func(s *Strategy) bool {
+			reason := Ineligible(g, now, s.Thresholds)
+			if reason != "" && g.Auction != nil && g.GiftID >= 0 {
+				o := auctionOpportunity(g, now)
+				e.observe(o.forStrategy(s), reason)
+			}
+			return reason == ""
+		})
+		if len(groups) == 0 {
+			continue
+		}
+		for _, group := range groups {
+			jobs = append(jobs, auctionJob{gift: g, group: group})
+		}
+		end := g.Auction.AuctionEndTime
+		if end.After(latest) {
+			latest = end
+		}
+		filteredGifts = append(filteredGifts, g)
+	}
+	earliest := latest
+	for _, g := range filteredGifts {
+		end := g.Auction.AuctionEndTime
+		if end.Before(earliest) {
+			earliest = end
+		}
+	}
+
+	metrics.AuctionsEligible.Add(float64(len(filteredGifts)))
+	now := e.opt.Clock.Now()
+	slog.Info("fetched auctions", "count", len(gifts), "eligible", len(filteredGifts), "first_end", earliest.Sub(now), "last_end", latest.Sub(now))
+	ch := generate(jobs, e.opt.Concurrency, func(j auctionJob) giftWithFloor {
+		g := j.gift
+		ctx, span := tracing.Tracer().Start(ctx, "evaluate auction", trace.WithAttributes(
+			attribute.Int("gift_id", g.GiftID),
+			attribute.String("auction_id", g.AuctionID),
+			attribute.String("gift", g.Name),
+			attribute.StringSlice("strategies", strategyNames(j.group.strategies)),
+		))
+		quote, err := r.Floor.Floor(ctx, j.group.key)
+		return giftWithFloor{gift: g, key: j.group.key, strategies: j.group.strategies, quote: quote, err: err, span: span}
+	})
+	for gf := range ch {
+		if gf.err != nil {
+			slog.Warn("floor lookup failed", "gift_id", gf.gift.GiftID, "auction_id", gf.gift.AuctionID, "err", gf.err)
+			tracing.End(gf.span, gf.err)
+			o := auctionOpportunity(gf.gift, e.opt.Clock.Now())
+			for _, s := range gf.strategies {
+				e.observe(o.forStrategy(s), "floor lookup failed: "+gf.err.Error())
+			}
+			continue
+		}
+		ctx := trace.ContextWithSpan(ctx, gf.span)
+		opportunities := e.evaluateAuction(ctx, r, gf)
+		for _, o := range opportunities {
+			e.notify(ctx, o)
+		}
+		gf.span.SetAttributes(attribute.Int("alerts", len(opportunities)))
+		gf.span.End()
+	}
+
+	return latest, nil
+}
+
+// one opportunity per strategy whose thresholds the auction passes
+func (e *Engine) evaluateAuction(ctx context.Context, r *Rules, gf giftWithFloor) []Opportunity {
+	g := gf.gift
+	now := e.opt.Clock.Now()
+	o := auctionOpportunity(g, now)
+	o.SellMarket = gf.quote.Source
+	o.Quote = gf.quote
+	o.Profit = ProfitOf(o.Price, gf.quote.Price)
+	p := o.Profit
+	if now.After(o.EndsAt) {
+		for _, s := range gf.strategies {
+			e.observe(o.forStrategy(s), "auction ended")
+		}
+		return nil
+	}
+	slog.Debug("auction evaluated", "gift_id", g.GiftID, "auction_id", g.AuctionID, "gift", g.Name, "num", g.GiftNum, "bid", o.Price, "floor", p.Floor, "asset", g.Asset, "profit_pct", p.Percentage*100, "ends_in", o.EndsAt.Sub(now))
+
+	passed := []*Strategy{}
+	for _, s := range gf.strategies {
+		if reason := Check(p, gf.quote, s.ThresholdsFor(g.Name)); reason != "" {
+			slog.Debug("auction rejected", "gift_id", g.GiftID, "strategy", s.Name, "reason", reason)
+			e.observe(o.forStrategy(s), reason)
+			continue
+		}
+		// auctions are scanned again before they end, a new bid is alerted again
+		if !e.opt.Dedupe.First(ctx, fmt.Sprintf("%s:auction:%d:%f", s.Name, g.GiftID, o.Price)) {
+			e.observe(o.forStrategy(s), "already alerted")
+			continue
+		}
+		passed = append(passed, s)
+	}
+	if len(passed) == 0 {
+		return nil
+	}
+
+	others := []pricing.Quote{}
+	if len(gf.quote.Parts) > 1 {
+		others = append(others, gf.quote.Parts...)
+	}
+	for _, src := range r.Others {
+		q, err := src.Floor(ctx, gf.key)
+		if err != nil {
+			slog.Warn("floor lookup failed", "gift_id", g.GiftID, "auction_id", g.AuctionID, "err", err)
+			continue
+		}
+		others = append(others, q)
+	}
+
+	o.Others = others
+	opportunities := make([]Opportunity, len(passed))
+	for i, s := range passed {
+		opportunities[i] = o.forStrategy(s)
+	}
+	return opportunities
+}
+
+// the auction without a floor, g must have an auction
+func auctionOpportunity(g tonnel.Gift, now time.Time) Opportunity {
+	return Opportunity{
+		Kind:     KindAuction,
+		Market:   pricing.SourceTonnel,
+		ID:       strconv.Itoa(g.GiftID),
+		GiftID:   g.GiftID,
+		Name:     g.Name,
+		Num:      g.GiftNum,
+		Model:    g.Model,
+		Backdrop: g.Backdrop,
+		Symbol:   g.Symbol,
+		Price:    g.MinBid(),
+		EndsAt:   g.Auction.AuctionEndTime,
+		BuyURL:   TonnelGiftURL(g.GiftID),
+		FoundAt:  now,
+	}
+}
@@ -0,0 +1,52 @@
+package scanner
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type Dedupe interface {
+	// reports whether key was not seen within the ttl and marks it as seen
+	First(ctx context.Context, key string) bool
+}
+
+type dedupe struct {
+	rdb  *redis.Client
+	ttl  time.Duration
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// rdb is optional, without it keys are only remembered in memory
+func NewDedupe(rdb *redis.Client, ttl time.Duration) Dedupe {
+	return &dedupe{rdb: rdb, ttl: ttl, seen: map[string]time.Time{}}
+}
+
+func (d *dedupe) First(ctx context.Context, key string) bool {
+	if d.rdb != nil {
+		ok, err := d.rdb.SetNX(ctx, "alerted:"+key, 1, d.ttl).Result()
+		if err == nil {
+			return ok
+		}
+		slog.Warn("redis dedupe failed", "key", key, "err", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	for k, t := range d.seen {
+		if now.Sub(t) > d.ttl {
+			delete(d.seen, k)
+		}
+	}
+	if _, ok := d.seen[key]; ok {
+		return false
+	}
+	d.seen[key] = now
+	return true
+}
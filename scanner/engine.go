@@ -0,0 +1,322 @@
+package scanner
+
+import (
+	"autobid/metrics"
+	"autobid/pricing"
+	"autobid/tonnel"
+	"autobid/tracing"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	KindAuction = "auction"
+	KindListing = "listing"
+)
+
+type AuctionSource interface {
+	Auctions(ctx context.Context) ([]tonnel.Gift, error)
+}
+
+type ListingSource interface {
+	Listings(ctx context.Context) ([]Listing, error)
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, o Opportunity) error
+}
+
+// Observer is told about every gift checked against a strategy, reason is
+// why no alert was sent, empty for alerts
+type Observer interface {
+	Observe(o Opportunity, reason string)
+}
+
+type Alerter interface {
+	Alert(ctx context.Context, text string) error
+}
+
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+var SystemClock Clock = systemClock{}
+
+type Listing struct {
+	Market   string
+	ID       string
+	GiftID   int // 0 if unknown
+	Name     string
+	Num      int
+	Model    string
+	Backdrop string
+	Symbol   string
+	Price    float64
+	BuyURL   string
+}
+
+type Opportunity struct {
+	Kind       string
+	Strategy   string
+	Chat       int64  // 0 is the default chat
+	Market     string // where to buy
+	SellMarket string
+	ID         string // gift id for auctions, listing id otherwise
+	GiftID     int    // 0 if unknown
+	Name       string
+	Num        int
+	Model      string
+	Backdrop   string
+	Symbol     string
+	Price      float64 // bid to place or listing price
+	Quote      pricing.Quote
+	Others     []pricing.Quote // floors shown next to the one the profit is computed against
+	Fee        float64         // taken when selling on SellMarket
+	Profit     Profit
+	EndsAt     time.Time // zero for listings
+	BuyURL     string
+	FoundAt    time.Time
+}
+
+// Rules can be swapped with Reload while the engine runs, a scan uses the
+// rules that were active when it started
+type Rules struct {
+	Floor      pricing.PriceSource            // floor auctions are compared against
+	Others     map[string]pricing.PriceSource // shown in auction alerts only
+	Fees       map[string]float64             // per market
+	Strategies []Strategy                     // every gift is checked against each
+}
+
+type Options struct {
+	Rules
+	Mode         string // KindAuction or KindListing
+	Auctions     AuctionSource
+	Listings     ListingSource
+	Markets      map[string]pricing.PriceSource // listings are compared against every other market
+	Notifier     Notifier
+	Observer     Observer // optional, sees every evaluation
+	Dedupe       Dedupe   // skips alerts already sent for the same price
+	Clock        Clock
+	Concurrency  int
+	ScanInterval time.Duration // between listing scans
+
+	MinPollInterval time.Duration
+	MaxPollInterval time.Duration // 0 is unlimited
+	RetryBackoff    time.Duration // first wait after a failed scan, doubled while failures persist
+	MaxRetryBackoff time.Duration
+	ErrorAlertAfter int     // consecutive failed scans before the admin is alerted, 0 never alerts
+	Admin           Alerter // optional
+}
+
+type Engine struct {
+	opt   *Options
+	rules atomic.Pointer[Rules]
+
+	mu     sync.Mutex
+	status Status
+}
+
+type Status struct {
+	Started     time.Time
+	LastSuccess time.Time // zero until the first scan succeeds
+	LastError   error
+	Failures    int // consecutive
+}
+
+func New(opt *Options) (*Engine, error) {
+	if opt.Clock == nil {
+		opt.Clock = SystemClock
+	}
+	if opt.Concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be positive, got %d", opt.Concurrency)
+	}
+	if opt.MaxPollInterval > 0 && opt.MaxPollInterval < opt.MinPollInterval {
+		return nil, fmt.Errorf("max poll interval %s is below min poll interval %s", opt.MaxPollInterval, opt.MinPollInterval)
+	}
+	if opt.RetryBackoff <= 0 {
+		opt.RetryBackoff = 5 * time.Second
+	}
+	if opt.MaxRetryBackoff < opt.RetryBackoff {
+		opt.MaxRetryBackoff = opt.RetryBackoff
+	}
+	switch opt.Mode {
+	case KindAuction:
+		if opt.Auctions == nil {
+			return nil, fmt.Errorf("auction scan needs an auction source")
+		}
+	case KindListing:
+		if opt.Listings == nil || len(opt.Markets) < 2 {
+			return nil, fmt.Errorf("listing scan needs a listing source and at least two markets")
+		}
+	default:
+		return nil, fmt.Errorf("unknown scan mode %q", opt.Mode)
+	}
+	if opt.Dedupe == nil {
+		opt.Dedupe = NewDedupe(nil, time.Hour)
+	}
+	e := &Engine{opt: opt, status: Status{Started: opt.Clock.Now()}}
+	if err := e.Reload(opt.Rules); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Reload swaps the rules used from the next scan on
+func (e *Engine) Reload(r Rules) error {
+	if e.opt.Mode == KindAuction && r.Floor == nil {
+		return fmt.Errorf("auction scan needs a floor source")
+	}
+	if len(r.Strategies) == 0 {
+		return fmt.Errorf("at least one strategy is needed")
+	}
+	e.rules.Store(&r)
+	return nil
+}
+
+func (e *Engine) Rules() Rules {
+	return *e.rules.Load()
+}
+
+func (e *Engine) Status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.status
+}
+
+func (e *Engine) setStatus(err error, failures int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.status.LastError = err
+	e.status.Failures = failures
+	if err == nil {
+		e.status.LastSuccess = e.opt.Clock.Now()
+	}
+}
+
+// scans until ctx is canceled, failed scans are retried with backoff
+func (e *Engine) Run(ctx context.Context) error {
+	failures := 0
+	alerted := false
+	for {
+		start := e.opt.Clock.Now()
+		cycleCtx, span := tracing.Tracer().Start(ctx, "scan "+e.opt.Mode)
+		wait, err := e.cycle(cycleCtx)
+		tracing.End(span, err)
+		if ctx.Err() == nil {
+			metrics.ObserveScan(e.opt.Mode, e.opt.Clock.Now().Sub(start), err)
+		}
+		if err != nil {
+			// a canceled request of a live ctx is a failed scan like any other
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			class := Classify(err)
+
+			failures++
+			e.setStatus(err, failures)
+			wait = backoff(err, failures, e.opt.RetryBackoff, e.opt.MaxRetryBackoff)
+			slog.Error("scan failed", "mode", e.opt.Mode, "class", class, "failures", failures, "retry_in", wait, "err", err)
+			if failures == e.opt.ErrorAlertAfter {
+				e.alert(ctx, fmt.Sprintf("Scanner failed %d times in a row (%s): %v", failures, class, err))
+				alerted = true
+			}
+		} else {
+			if alerted {
+				e.alert(ctx, fmt.Sprintf("Scanner recovered after %d failed scans", failures))
+			}
+			failures, alerted = 0, false
+			e.setStatus(nil, 0)
+
+			wait = max(wait, e.opt.MinPollInterval)
+			if e.opt.MaxPollInterval > 0 {
+				wait = min(wait, e.opt.MaxPollInterval)
+			}
+			slog.Info("scan finished", "mode", e.opt.Mode, "duration", e.opt.Clock.Now().Sub(start), "next_in", wait)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-e.opt.Clock.After(wait):
+		}
+	}
+}
+
+// returns how long to wait before the next scan
+func (e *Engine) cycle(ctx context.Context) (time.Duration, error) {
+	if e.opt.Mode == KindListing {
+		return e.opt.ScanInterval, e.ScanListings(ctx)
+	}
+	next, err := e.ScanAuctions(ctx)
+	return next.Sub(e.opt.Clock.Now()), err
+}
+
+func (e *Engine) alert(ctx context.Context, text string) {
+	if e.opt.Admin == nil {
+		return
+	}
+	if err := e.opt.Admin.Alert(ctx, text); err != nil {
+		slog.Error("alerting admin failed", "err", err)
+	}
+}
+
+func (e *Engine) notify(ctx context.Context, o Opportunity) {
+	e.observe(o, "")
+	if e.opt.Notifier == nil {
+		return
+	}
+	slog.Info("opportunity found", "kind", o.Kind, "strategy", o.Strategy, "market", o.Market, "id", o.ID, "gift_id", o.GiftID, "gift", o.Name, "num", o.Num, "price", o.Price, "floor", o.Quote.Price, "profit_pct", o.Profit.Percentage*100)
+	if err := e.opt.Notifier.Notify(ctx, o); err != nil {
+		slog.Error("notifying failed", "market", o.Market, "id", o.ID, "gift_id", o.GiftID, "err", err)
+	}
+}
+
+func (e *Engine) observe(o Opportunity, reason string) {
+	if e.opt.Observer != nil {
+		e.opt.Observer.Observe(o, reason)
+	}
+}
+
+// o as seen by strategy s
+func (o Opportunity) forStrategy(s *Strategy) Opportunity {
+	o.Strategy = s.Name
+	o.Chat = s.Chat
+	return o
+}
+
+// runs fn for every item with at most limit calls in flight, results arrive as they finish
+func generate[T, R any](items []T, limit int, fn func(T) R) <-chan R {
+	out := make(chan R)
+	go func() {
+		defer close(out)
+
+		sem := make(chan struct{}, limit)
+		var wg sync.WaitGroup
+
+		for _, item := range items {
+			wg.Add(1)
+			go func(item T) {
+				defer wg.Done()
+
+				sem <- struct{}{}
+				r := fn(item)
+				<-sem
+
+				out <- r
+			}(item)
+		}
+
+		wg.Wait()
+	}()
+	return out
+}
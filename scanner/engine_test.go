@@ -0,0 +1,240 @@
+package scanner
+
+import (
+	"autobid/config"
+	"autobid/pricing"
+	"autobid/tlsclient"
+	"autobid/tonnel"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now   time.Time
+	waits []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+// fires at once, the waits are recorded
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// returns the scripted results one scan after another, then calls stop
+type fakeAuctions struct {
+	scans [][]tonnel.Gift
+	errs  []error
+	stop  func()
+	calls int
+}
+
+func (s *fakeAuctions) Auctions(ctx context.Context) ([]tonnel.Gift, error) {
+	i := s.calls
+	s.calls++
+	if s.stop != nil && i >= max(len(s.scans), len(s.errs)) {
+		s.stop()
+		return nil, ctx.Err()
+	}
+	if i < len(s.errs) && s.errs[i] != nil {
+		return nil, s.errs[i]
+	}
+	if i < len(s.scans) {
+		return s.scans[i], nil
+	}
+	return nil, nil
+}
+
+type fakeFloors map[string]float64
+
+func (f fakeFloors) Floor(ctx context.Context, key pricing.GiftKey) (pricing.Quote, error) {
+	price, ok := f[key.Name]
+	if !ok {
+		return pricing.Quote{}, &pricing.NoFloorError{Source: pricing.SourceTonnel, Key: key}
+	}
+	return pricing.Quote{Source: pricing.SourceTonnel, Key: key, Price: price}, nil
+}
+
+type fakeNotifier struct {
+	sent []Opportunity
+}
+
+func (n *fakeNotifier) Notify(ctx context.Context, o Opportunity) error {
+	n.sent = append(n.sent, o)
+	return nil
+}
+
+type fakeObserver map[int][]string
+
+func (o fakeObserver) Observe(opp Opportunity, reason string) {
+	if reason != "" {
+		o[opp.GiftID] = append(o[opp.GiftID], reason)
+	}
+}
+
+type fakeAdmin struct {
+	alerts []string
+}
+
+func (a *fakeAdmin) Alert(ctx context.Context, text string) error {
+	a.alerts = append(a.alerts, text)
+	return nil
+}
+
+func testGift(id int, name string, end time.Time, bids ...float64) tonnel.Gift {
+	a := &tonnel.Auction{GiftID: id, StartingBid: 10, AuctionEndTime: end}
+	for _, b := range bids {
+		a.BidHistory = append(a.BidHistory, tonnel.BidHistoryEntry{Amount: b})
+	}
+	return tonnel.Gift{GiftID: id, GiftNum: id, Name: name, Model: "Gold", Auction: a}
+}
+
+func TestScanAuctions(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	end := clock.now.Add(time.Hour)
+	strategies, err := StrategiesFromConfig(&config.Config{MinProfit: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &fakeAuctions{scans: [][]tonnel.Gift{
+		{
+			testGift(1, "Cat", end),
+			testGift(2, "Dog", end),
+			testGift(3, "Cat", clock.now.Add(-time.Minute)),
+			testGift(4, "Plush", end),
+		},
+		// unchanged, already alerted
+		{testGift(1, "Cat", end)},
+		// outbid, the higher bid is still worth it
+		{testGift(1, "Cat", end, 11)},
+	}}
+	notifier := &fakeNotifier{}
+	observer := fakeObserver{}
+	e, err := New(&Options{
+		Rules:       Rules{Floor: fakeFloors{"Cat": 15, "Dog": 10.5}, Strategies: strategies},
+		Mode:        KindAuction,
+		Auctions:    source,
+		Notifier:    notifier,
+		Observer:    observer,
+		Clock:       clock,
+		Concurrency: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []float64{10, 0, 11.55} {
+		notifier.sent = nil
+		next, err := e.ScanAuctions(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !next.Equal(end) {
+			t.Errorf("scan %d: next scan at %s, want %s", i, next, end)
+		}
+		if want == 0 {
+			if len(notifier.sent) != 0 {
+				t.Errorf("scan %d: alerted %+v, want none", i, notifier.sent)
+			}
+			continue
+		}
+		if len(notifier.sent) != 1 {
+			t.Fatalf("scan %d: alerted %+v, want gift 1", i, notifier.sent)
+		}
+		o := notifier.sent[0]
+		if o.GiftID != 1 || o.Price != want || o.Quote.Price != 15 || o.Strategy != config.DefaultStrategy {
+			t.Errorf("scan %d: alerted %+v, want gift 1 at %v", i, o, want)
+		}
+	}
+
+	for id, want := range map[int]string{
+		1: "already alerted",
+		2: "below threshold",
+		3: "before min_auction_end",
+		4: "floor lookup failed",
+	} {
+		reasons := observer[id]
+		if len(reasons) != 1 || !strings.Contains(reasons[0], want) {
+			t.Errorf("gift %d observed %q, want %q", id, reasons, want)
+		}
+	}
+}
+
+func TestRunRetriesWithBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	failed := errors.New("502: bad gateway")
+	// a request canceled on its own while ctx is live is a failed scan
+	timedOut := fmt.Errorf("request: %w", context.Canceled)
+	clock := &fakeClock{now: time.Now()}
+	source := &fakeAuctions{errs: []error{failed, timedOut, failed, nil}, stop: cancel}
+	admin := &fakeAdmin{}
+	e, err := New(&Options{
+		Rules:           Rules{Floor: fakeFloors{}, Strategies: []Strategy{{Name: config.DefaultStrategy}}},
+		Mode:            KindAuction,
+		Auctions:        source,
+		Clock:           clock,
+		Concurrency:     1,
+		MinPollInterval: 3 * time.Second,
+		RetryBackoff:    time.Second,
+		MaxRetryBackoff: 3 * time.Second,
+		ErrorAlertAfter: 2,
+		Admin:           admin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run returned %v, want the cancellation", err)
+	}
+	if source.calls != 5 {
+		t.Errorf("%d scans, want 5", source.calls)
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}
+	if len(clock.waits) != len(want) {
+		t.Fatalf("waited %v, want %v", clock.waits, want)
+	}
+	for i := range want {
+		if clock.waits[i] != want[i] {
+			t.Errorf("waited %v, want %v", clock.waits, want)
+			break
+		}
+	}
+	if len(admin.alerts) != 2 || !strings.Contains(admin.alerts[0], "failed 2 times") || !strings.Contains(admin.alerts[1], "recovered after 3") {
+		t.Errorf("admin alerts %q, want a failure and a recovery", admin.alerts)
+	}
+	if s := e.Status(); s.Failures != 0 || s.LastError != nil || s.LastSuccess.IsZero() {
+		t.Errorf("status %+v, want recovered", s)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	failed := errors.New("502: bad gateway")
+	flood := &tlsclient.FloodWaitError{StatusCode: 429, RetryAfter: 30}
+	tests := []struct {
+		err      error
+		failures int
+		want     time.Duration
+	}{
+		{failed, 1, 5 * time.Second},
+		{failed, 2, 10 * time.Second},
+		{failed, 4, 40 * time.Second},
+		{failed, 10, time.Minute},
+		{flood, 1, 30 * time.Second},
+		{flood, 4, 40 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.err, tt.failures, 5*time.Second, time.Minute); got != tt.want {
+			t.Errorf("backoff(%v, %d) = %s, want %s", tt.err, tt.failures, got, tt.want)
+		}
+	}
+}
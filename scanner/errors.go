@@ -0,0 +1,47 @@
+package scanner
+
+import (
+	"autobid/tlsclient"
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+type ErrorClass string
+
+const (
+	ErrorCanceled ErrorClass = "canceled"
+	ErrorFlood    ErrorClass = "flood"
+	ErrorNetwork  ErrorClass = "network"
+	ErrorUpstream ErrorClass = "upstream" // unexpected status or payload
+)
+
+func Classify(err error) ErrorClass {
+	var flood *tlsclient.FloodWaitError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorCanceled
+	case errors.As(err, &flood):
+		return ErrorFlood
+	case errors.As(err, &netErr), errors.Is(err, tlsclient.ErrMaxRetries):
+		return ErrorNetwork
+	}
+	return ErrorUpstream
+}
+
+// doubles with every consecutive failure, flood waits are never cut short
+func backoff(err error, failures int, base, limit time.Duration) time.Duration {
+	d := base
+	for i := 1; i < failures && d < limit; i++ {
+		d *= 2
+	}
+	d = min(d, limit)
+
+	var flood *tlsclient.FloodWaitError
+	if errors.As(err, &flood) {
+		d = max(d, time.Duration(flood.RetryAfter*float64(time.Second)))
+	}
+	return d
+}
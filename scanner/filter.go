@@ -0,0 +1,111 @@
+package scanner
+
+import (
+	"autobid/config"
+	"autobid/pricing"
+	"autobid/tonnel"
+	"fmt"
+	"time"
+)
+
+type Thresholds struct {
+	MinProfit      float64
+	MinProfitTon   float64
+	MaxBid         float64 // TON spent on one gift, 0 is unlimited
+	MinBids        uint32
+	MaxBids        uint32 // 0 is unlimited
+	MinAuctionEnd  time.Duration
+	MaxAuctionEnd  time.Duration // 0 is unlimited
+	MinNearFloor   int
+	MinRecentSales int
+	// percent of the collection, 0 is unlimited
+	MaxModelRarity    float64
+	MaxCombinedRarity float64
+}
+
+func ThresholdsFromConfig(cfg *config.Config) Thresholds {
+	return Thresholds{
+		MinProfit:         cfg.MinProfit,
+		MinProfitTon:      cfg.MinProfitTon,
+		MaxBid:            cfg.MaxBid,
+		MinBids:           cfg.MinBids,
+		MaxBids:           cfg.MaxBids,
+		MinAuctionEnd:     time.Duration(cfg.MinAuctionEnd * float64(time.Second)),
+		MaxAuctionEnd:     time.Duration(cfg.MaxAuctionEnd * float64(time.Second)),
+		MinNearFloor:      cfg.MinNearFloor,
+		MinRecentSales:    cfg.MinRecentSales,
+		MaxModelRarity:    cfg.MaxModelRarity,
+		MaxCombinedRarity: cfg.MaxCombinedRarity,
+	}
+}
+
+// auction checks done before any floor lookup
+func Eligible(g tonnel.Gift, now time.Time, t Thresholds) bool {
+	return Ineligible(g, now, t) == ""
+}
+
+// returns why an auction is skipped before any floor lookup, empty if it is not
+func Ineligible(g tonnel.Gift, now time.Time, t Thresholds) string {
+	if g.GiftID < 0 || g.Auction == nil {
+		return "not an auction"
+	}
+	endsIn := g.Auction.AuctionEndTime.Sub(now)
+	if endsIn < t.MinAuctionEnd {
+		return fmt.Sprintf("ends in %s, before min_auction_end", endsIn.Round(time.Second))
+	}
+	if t.MaxAuctionEnd > 0 && endsIn > t.MaxAuctionEnd {
+		return fmt.Sprintf("ends in %s, after max_auction_end", endsIn.Round(time.Second))
+	}
+	bids := len(g.Auction.BidHistory)
+	if bids < int(t.MinBids) || (t.MaxBids > 0 && bids > int(t.MaxBids)) {
+		return fmt.Sprintf("%d bids outside min_bids/max_bids", bids)
+	}
+	if !RareEnough(g.ModelTrait(), g.BackdropTrait(), g.SymbolTrait(), t) {
+		return "not rare enough"
+	}
+	return ""
+}
+
+// rarity limits reject gifts whose rarity is unknown
+func RareEnough(model, backdrop, symbol tonnel.Trait, t Thresholds) bool {
+	if t.MaxModelRarity > 0 && (model.Rarity <= 0 || model.Rarity > t.MaxModelRarity) {
+		return false
+	}
+	if t.MaxCombinedRarity > 0 {
+		combined := tonnel.CombinedRarity(model, backdrop, symbol)
+		if combined <= 0 || combined > t.MaxCombinedRarity {
+			return false
+		}
+	}
+	return true
+}
+
+type Profit struct {
+	Price      float64
+	Floor      float64
+	Ton        float64
+	Percentage float64 // fraction of the floor, 0.1 is 10%
+}
+
+func ProfitOf(price, floor float64) Profit {
+	return Profit{
+		Price:      price,
+		Floor:      floor,
+		Ton:        floor - price,
+		Percentage: 1 - (price / floor),
+	}
+}
+
+// returns why an opportunity is rejected, empty if it is not
+func Check(p Profit, q pricing.Quote, t Thresholds) string {
+	if t.MaxBid > 0 && p.Price > t.MaxBid {
+		return fmt.Sprintf("price %f TON above budget %f TON", p.Price, t.MaxBid)
+	}
+	if p.Percentage < t.MinProfit || p.Ton < t.MinProfitTon {
+		return fmt.Sprintf("profit %f%% (%f TON) below threshold", p.Percentage*100, p.Ton)
+	}
+	if q.NearFloor < t.MinNearFloor || q.RecentSales < t.MinRecentSales {
+		return fmt.Sprintf("illiquid (%d near floor, %d recent sales)", q.NearFloor, q.RecentSales)
+	}
+	return ""
+}
@@ -0,0 +1,141 @@
+package scanner
+
+import (
+	"autobid/metrics"
+	"autobid/pricing"
+	"autobid/tonnel"
+	"autobid/tracing"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type listingWithFloor struct {
+	listing    Listing
+	market     string
+	strategies []*Strategy
+	quote      pricing.Quote
+	err        error
+	span       trace.Span // ended once the listing is evaluated
+}
+
+type listingJob struct {
+	listing Listing
+	market  string
+	source  pricing.PriceSource
+	group   strategyGroup
+}
+
+// compares every listing against the floor of each other market
+func (e *Engine) ScanListings(ctx context.Context) error {
+	slog.Debug("fetching listings")
+	r := e.rules.Load()
+	listings, err := e.opt.Listings.Listings(ctx)
+	if err != nil {
+		return err
+	}
+	slog.Info("fetched listings", "count", len(listings))
+	for _, l := range listings {
+		metrics.ListingsFetched.WithLabelValues(l.Market).Inc()
+	}
+
+	jobs := []listingJob{}
+	for _, l := range listings {
+		groups := groupStrategies(r.Strategies, l.Name, l.Model, l.Backdrop, l.Symbol, func(s *Strategy) bool {
+			if !RareEnough(tonnel.ParseTrait(l.Model), tonnel.ParseTrait(l.Backdrop), tonnel.ParseTrait(l.Symbol), s.Thresholds) {
+				o := listingOpportunity(l, "", e.opt.Clock.Now())
+				e.observe(o.forStrategy(s), "not rare enough")
+				return false
+			}
+			return true
+		})
+		for market, src := range e.opt.Markets {
+			if market == l.Market {
+				continue
+			}
+			for _, group := range groups {
+				jobs = append(jobs, listingJob{listing: l, market: market, source: src, group: group})
+			}
+		}
+	}
+
+	ch := generate(jobs, e.opt.Concurrency, func(j listingJob) listingWithFloor {
+		ctx, span := tracing.Tracer().Start(ctx, "evaluate listing", trace.WithAttributes(
+			attribute.String("market", j.listing.Market),
+			attribute.String("listing_id", j.listing.ID),
+			attribute.Int("gift_id", j.listing.GiftID),
+			attribute.String("sell_market", j.market),
+			attribute.StringSlice("strategies", strategyNames(j.group.strategies)),
+		))
+		quote, err := j.source.Floor(ctx, j.group.key)
+		return listingWithFloor{listing: j.listing, market: j.market, strategies: j.group.strategies, quote: quote, err: err, span: span}
+	})
+	for lf := range ch {
+		if lf.err != nil {
+			slog.Warn("floor lookup failed", "market", lf.listing.Market, "listing_id", lf.listing.ID, "source", lf.market, "err", lf.err)
+			tracing.End(lf.span, lf.err)
+			o := listingOpportunity(lf.listing, lf.market, e.opt.Clock.Now())
+			for _, s := range lf.strategies {
+				e.observe(o.forStrategy(s), "floor lookup failed: "+lf.err.Error())
+			}
+			continue
+		}
+		ctx := trace.ContextWithSpan(ctx, lf.span)
+		opportunities := e.evaluateListing(ctx, r, lf)
+		for _, o := range opportunities {
+			e.notify(ctx, o)
+		}
+		lf.span.SetAttributes(attribute.Int("alerts", len(opportunities)))
+		lf.span.End()
+	}
+	return nil
+}
+
+// one opportunity per strategy whose thresholds the listing passes, each is alerted once
+func (e *Engine) evaluateListing(ctx context.Context, r *Rules, lf listingWithFloor) []Opportunity {
+	l := lf.listing
+	o := listingOpportunity(l, lf.market, e.opt.Clock.Now())
+	o.Quote = lf.quote
+	o.Fee = r.Fees[lf.market]
+	o.Profit = ProfitOf(l.Price, lf.quote.Price*(1-o.Fee))
+	p := o.Profit
+	slog.Debug("listing evaluated", "market", l.Market, "listing_id", l.ID, "gift_id", l.GiftID, "gift", l.Name, "num", l.Num, "price", l.Price, "sell_market", lf.market, "floor", lf.quote.Price, "profit_pct", p.Percentage*100)
+
+	opportunities := []Opportunity{}
+	for _, s := range lf.strategies {
+		if reason := Check(p, lf.quote, s.ThresholdsFor(l.Name)); reason != "" {
+			slog.Debug("listing rejected", "market", l.Market, "listing_id", l.ID, "strategy", s.Name, "reason", reason)
+			e.observe(o.forStrategy(s), reason)
+			continue
+		}
+		if !e.opt.Dedupe.First(ctx, fmt.Sprintf("%s:%s:%s:%f", s.Name, l.Market, l.ID, l.Price)) {
+			e.observe(o.forStrategy(s), "already alerted")
+			continue
+		}
+		opportunities = append(opportunities, o.forStrategy(s))
+	}
+	return opportunities
+}
+
+// the listing without a floor, sellMarket may be empty
+func listingOpportunity(l Listing, sellMarket string, now time.Time) Opportunity {
+	return Opportunity{
+		Kind:       KindListing,
+		Market:     l.Market,
+		SellMarket: sellMarket,
+		ID:         l.ID,
+		GiftID:     l.GiftID,
+		Name:       l.Name,
+		Num:        l.Num,
+		Model:      l.Model,
+		Backdrop:   l.Backdrop,
+		Symbol:     l.Symbol,
+		Price:      l.Price,
+		BuyURL:     l.BuyURL,
+		FoundAt:    now,
+	}
+}
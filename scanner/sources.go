@@ -0,0 +1,172 @@
+package scanner
+
+import (
+	"autobid/portal"
+	"autobid/pricing"
+	"autobid/tonnel"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type GiftRecorder interface {
+	RecordGifts(gifts []tonnel.Gift, seenAt time.Time) error
+}
+
+func TonnelGiftURL(giftID int) string {
+	return fmt.Sprintf("https://t.me/tonnel_network_bot/gift?startapp=%d", giftID)
+}
+
+const PortalsURL = "https://t.me/portals/market?startapp=7t5no1"
+
+type TonnelAuctions struct {
+	Proxies  []*url.URL
+	Offset   uint32
+	Limit    uint32
+	Recorder GiftRecorder // optional
+
+	client *tonnel.TonnelAPI // reused between scans, dropped after an error
+}
+
+func (s *TonnelAuctions) Auctions(ctx context.Context) ([]tonnel.Gift, error) {
+	if s.client == nil {
+		client, err := tonnel.New(&tonnel.Options{
+			Proxies: s.Proxies,
+		})
+		if err != nil {
+			return nil, err
+		}
+		s.client = client
+	}
+
+	gifts, err := s.client.GetAuctions(ctx, 1+s.Offset, s.Limit)
+	if err != nil {
+		// the connection may be dead, the next scan dials a new one
+		s.client.Close()
+		s.client = nil
+		return nil, fmt.Errorf("error GetAuctions: %w", err)
+	}
+	record(s.Recorder, gifts)
+	return gifts, nil
+}
+
+func (s *TonnelAuctions) Close() error {
+	if s.client == nil {
+		return nil
+	}
+	return s.client.Close()
+}
+
+// newest fixed-price listings of Tonnel and Portals
+type MarketListings struct {
+	Proxies     []*url.URL
+	PortalsAuth string
+	Offset      uint32
+	Limit       uint32
+	Recorder    GiftRecorder // optional
+}
+
+// fails only when no market could be fetched
+func (s *MarketListings) Listings(ctx context.Context) ([]Listing, error) {
+	tonnelListings, tonnelErr := s.tonnelListings(ctx)
+	if tonnelErr != nil {
+		slog.Error("fetching listings failed", "market", pricing.SourceTonnel, "err", tonnelErr)
+	}
+	portalsListings, portalsErr := s.portalsListings(ctx)
+	if portalsErr != nil {
+		slog.Error("fetching listings failed", "market", pricing.SourcePortals, "err", portalsErr)
+	}
+	if tonnelErr != nil && portalsErr != nil {
+		return nil, errors.Join(tonnelErr, portalsErr)
+	}
+	return append(tonnelListings, portalsListings...), nil
+}
+
+func (s *MarketListings) tonnelListings(ctx context.Context) ([]Listing, error) {
+	client, err := tonnel.New(&tonnel.Options{Proxies: s.Proxies})
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	gifts, err := client.GetListings(ctx, 1+s.Offset, s.Limit)
+	if err != nil {
+		return nil, err
+	}
+	record(s.Recorder, gifts)
+
+	listings := []Listing{}
+	for _, g := range gifts {
+		if g.GiftID < 0 || g.Price <= 0 {
+			continue
+		}
+		listings = append(listings, Listing{
+			Market:   pricing.SourceTonnel,
+			ID:       strconv.Itoa(g.GiftID),
+			GiftID:   g.GiftID,
+			Name:     g.Name,
+			Num:      g.GiftNum,
+			Model:    g.Model,
+			Backdrop: g.Backdrop,
+			Symbol:   g.Symbol,
+			Price:    g.Price,
+			BuyURL:   TonnelGiftURL(g.GiftID),
+		})
+	}
+	return listings, nil
+}
+
+func (s *MarketListings) portalsListings(ctx context.Context) ([]Listing, error) {
+	client, err := portal.New(&portal.Options{Proxies: s.Proxies, Auth: s.PortalsAuth})
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	results, err := client.SearchListings(ctx, s.Offset, s.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	listings := []Listing{}
+	for _, r := range results {
+		price, err := strconv.ParseFloat(r.Price, 64)
+		if err != nil || price <= 0 {
+			continue
+		}
+		listings = append(listings, Listing{
+			Market:   pricing.SourcePortals,
+			ID:       r.ID,
+			Name:     r.Name,
+			Num:      r.ExternalCollectionNumber,
+			Model:    tonnelTrait(r.Attribute("model")),
+			Backdrop: tonnelTrait(r.Attribute("backdrop")),
+			Symbol:   tonnelTrait(r.Attribute("symbol")),
+			Price:    price,
+			BuyURL:   PortalsURL,
+		})
+	}
+	return listings, nil
+}
+
+// formats portals attribute the way tonnel names traits, e.g. "Onyx Black (2%)".
+// The rarity can differ from tonnel's, floors match on the name only.
+func tonnelTrait(attr *portal.Attribute) string {
+	if attr == nil {
+		return ""
+	}
+	return tonnel.Trait{Name: attr.Value, Rarity: attr.RarityPerMille / 10}.String()
+}
+
+func record(recorder GiftRecorder, gifts []tonnel.Gift) {
+	if recorder == nil {
+		return
+	}
+	if err := recorder.RecordGifts(gifts, time.Now()); err != nil {
+		slog.Warn("recording gifts failed", "count", len(gifts), "err", err)
+	}
+}
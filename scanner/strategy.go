@@ -0,0 +1,243 @@
+package scanner
+
+import (
+	"autobid/config"
+	"autobid/match"
+	"autobid/pricing"
+	"autobid/rarity"
+	"fmt"
+	"time"
+)
+
+// Strategy is a named set of filters evaluated against every scanned gift
+type Strategy struct {
+	Name       string
+	Filters    []match.Filter // a gift must pass all of them
+	Overrides  []Override     // the first matching one applies
+	Thresholds Thresholds
+	Rarity     rarity.Rules // picks the floor each gift is compared with
+	Chat       int64        // 0 is the default chat
+}
+
+// Override replaces thresholds for the collections matching Collection, nil
+// values keep the strategy's
+type Override struct {
+	Collection   *match.Pattern
+	MinProfit    *float64
+	MinProfitTon *float64
+	MaxBid       *float64
+}
+
+// Allows reports whether the gift is scanned by s
+func (s *Strategy) Allows(name, model, backdrop string) bool {
+	for _, f := range s.Filters {
+		if !f.Allows(name, model, backdrop) {
+			return false
+		}
+	}
+	return true
+}
+
+// ThresholdsFor returns the thresholds for gifts of collection
+func (s *Strategy) ThresholdsFor(collection string) Thresholds {
+	t := s.Thresholds
+	for _, o := range s.Overrides {
+		if o.Collection.Match(collection) {
+			t.MinProfit = or(o.MinProfit, t.MinProfit)
+			t.MinProfitTon = or(o.MinProfitTon, t.MinProfitTon)
+			t.MaxBid = or(o.MaxBid, t.MaxBid)
+			break
+		}
+	}
+	return t
+}
+
+// StrategiesFromConfig returns the configured strategies with unset values
+// taken from the top level config, or a single default strategy
+func StrategiesFromConfig(cfg *config.Config) ([]Strategy, error) {
+	top, err := filter(nil, nil, cfg.Allow, cfg.Deny)
+	if err != nil {
+		return nil, err
+	}
+	topOverrides, err := overrides(cfg.Overrides)
+	if err != nil {
+		return nil, err
+	}
+	topRarity, err := RarityFromConfig(cfg.RareTraits, cfg.RareBackdrops)
+	if err != nil {
+		return nil, err
+	}
+	base := Strategy{
+		Name:       config.DefaultStrategy,
+		Filters:    []match.Filter{top},
+		Overrides:  topOverrides,
+		Thresholds: ThresholdsFromConfig(cfg),
+		Rarity:     topRarity,
+	}
+	if len(cfg.Strategies) == 0 {
+		return []Strategy{base}, nil
+	}
+
+	strategies := make([]Strategy, len(cfg.Strategies))
+	for i, s := range cfg.Strategies {
+		f, err := filter(s.Collections, s.Exclude, s.Allow, s.Deny)
+		if err != nil {
+			return nil, fmt.Errorf("strategy %s: %w", s.Name, err)
+		}
+		o, err := overrides(s.Overrides)
+		if err != nil {
+			return nil, fmt.Errorf("strategy %s: %w", s.Name, err)
+		}
+		rules := topRarity
+		if s.RareTraits != nil || s.RareBackdrops != nil {
+			rules, err = RarityFromConfig(inherit(s.RareTraits, cfg.RareTraits), inherit(s.RareBackdrops, cfg.RareBackdrops))
+			if err != nil {
+				return nil, fmt.Errorf("strategy %s: %w", s.Name, err)
+			}
+		}
+
+		t := base.Thresholds
+		strategies[i] = Strategy{
+			Name:      s.Name,
+			Filters:   []match.Filter{top, f},
+			Overrides: append(o, topOverrides...),
+			Thresholds: Thresholds{
+				MinProfit:         or(s.MinProfit, t.MinProfit),
+				MinProfitTon:      or(s.MinProfitTon, t.MinProfitTon),
+				MaxBid:            or(s.MaxBid, t.MaxBid),
+				MinBids:           or(s.MinBids, t.MinBids),
+				MaxBids:           or(s.MaxBids, t.MaxBids),
+				MinAuctionEnd:     seconds(s.MinAuctionEnd, t.MinAuctionEnd),
+				MaxAuctionEnd:     seconds(s.MaxAuctionEnd, t.MaxAuctionEnd),
+				MinNearFloor:      or(s.MinNearFloor, t.MinNearFloor),
+				MinRecentSales:    or(s.MinRecentSales, t.MinRecentSales),
+				MaxModelRarity:    or(s.MaxModelRarity, t.MaxModelRarity),
+				MaxCombinedRarity: or(s.MaxCombinedRarity, t.MaxCombinedRarity),
+			},
+			Rarity: rules,
+			Chat:   s.ChatID,
+		}
+	}
+	return strategies, nil
+}
+
+// collections and exclude are shorthands for allow and deny rules on the collection only
+func filter(collections, exclude []string, allow, deny []config.Match) (match.Filter, error) {
+	var f match.Filter
+	for _, c := range collections {
+		allow = append(allow, config.Match{Collection: c})
+	}
+	for _, c := range exclude {
+		deny = append(deny, config.Match{Collection: c})
+	}
+	for _, m := range allow {
+		r, err := match.NewRule(m.Collection, m.Model, m.Backdrop)
+		if err != nil {
+			return f, err
+		}
+		f.Allow = append(f.Allow, r)
+	}
+	for _, m := range deny {
+		r, err := match.NewRule(m.Collection, m.Model, m.Backdrop)
+		if err != nil {
+			return f, err
+		}
+		f.Deny = append(f.Deny, r)
+	}
+	return f, nil
+}
+
+// RarityFromConfig compiles the rare trait rules followed by a backdrop floor
+// rule for each rare backdrop
+func RarityFromConfig(traits []config.RareTrait, backdrops []string) (rarity.Rules, error) {
+	rules := make(rarity.Rules, 0, len(traits)+len(backdrops))
+	for i, t := range traits {
+		r, err := rarity.NewRule(t.Model, t.Backdrop, t.Symbol, t.Floor)
+		if err != nil {
+			return nil, fmt.Errorf("rare_traits[%d]: %w", i, err)
+		}
+		r.MaxModel, r.MaxBackdrop, r.MaxSymbol = t.MaxModelRarity, t.MaxBackdropRarity, t.MaxSymbolRarity
+		rules = append(rules, r)
+	}
+	for _, b := range backdrops {
+		r, err := rarity.NewRule("", b, "", rarity.FloorBackdrop)
+		if err != nil {
+			return nil, fmt.Errorf("rare_backdrops: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func overrides(list []config.Override) ([]Override, error) {
+	out := make([]Override, len(list))
+	for i, o := range list {
+		p, err := match.Compile(o.Collection)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = Override{Collection: p, MinProfit: o.MinProfit, MinProfitTon: o.MinProfitTon, MaxBid: o.MaxBid}
+	}
+	return out, nil
+}
+
+func or[T any](v *T, def T) T {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+// an unset list inherits def, an empty one clears it
+func inherit[T any](list, def []T) []T {
+	if list == nil {
+		return def
+	}
+	return list
+}
+
+func seconds(v *float64, def time.Duration) time.Duration {
+	if v == nil {
+		return def
+	}
+	return time.Duration(*v * float64(time.Second))
+}
+
+// strategies sharing a floor lookup for one gift
+type strategyGroup struct {
+	key        pricing.GiftKey
+	strategies []*Strategy
+}
+
+// groups the strategies that allow the gift by the floor key they look up,
+// keep is called for every allowing strategy and may reject it
+func groupStrategies(strategies []Strategy, name, model, backdrop, symbol string, keep func(*Strategy) bool) []strategyGroup {
+	groups := []strategyGroup{}
+	for i := range strategies {
+		s := &strategies[i]
+		if !s.Allows(name, model, backdrop) || !keep(s) {
+			continue
+		}
+		key := s.Rarity.KeyFor(name, model, backdrop, symbol)
+		found := false
+		for j := range groups {
+			if groups[j].key == key {
+				groups[j].strategies = append(groups[j].strategies, s)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, strategyGroup{key: key, strategies: []*Strategy{s}})
+		}
+	}
+	return groups
+}
+
+func strategyNames(strategies []*Strategy) []string {
+	names := make([]string, len(strategies))
+	for i, s := range strategies {
+		names[i] = s.Name
+	}
+	return names
+}
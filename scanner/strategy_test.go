@@ -0,0 +1,53 @@
+package scanner
+
+import (
+	"autobid/config"
+	"autobid/pricing"
+	"testing"
+)
+
+func TestStrategyRarityInheritance(t *testing.T) {
+	cfg := &config.Config{
+		RareBackdrops: []string{"Black"},
+		RareTraits:    []config.RareTrait{{Model: "Gold*", Floor: "collection"}},
+		Strategies: []config.Strategy{
+			{Name: "traits", RareTraits: []config.RareTrait{{Symbol: "Star", Floor: "model_backdrop"}}},
+			{Name: "backdrops", RareBackdrops: []string{"Red"}},
+			{Name: "cleared", RareBackdrops: []string{}},
+			{Name: "unset"},
+		},
+	}
+	strategies, err := StrategiesFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := map[string]Strategy{}
+	for _, s := range strategies {
+		byName[s.Name] = s
+	}
+
+	tests := []struct {
+		strategy, model, backdrop, symbol string
+		want                              pricing.GiftKey
+	}{
+		// rare_backdrops unset, the top level ones still apply
+		{"traits", "Plain", "Black", "Moon", pricing.GiftKey{Name: "Cat", Backdrop: "Black"}},
+		{"traits", "Plain", "Blue", "Star", pricing.GiftKey{Name: "Cat", Model: "Plain", Backdrop: "Blue"}},
+		{"traits", "Gold", "Blue", "Moon", pricing.GiftKey{Name: "Cat", Model: "Gold"}},
+		// rare_traits unset, the top level ones still apply
+		{"backdrops", "Gold", "Blue", "Moon", pricing.GiftKey{Name: "Cat"}},
+		{"backdrops", "Plain", "Red", "Moon", pricing.GiftKey{Name: "Cat", Backdrop: "Red"}},
+		{"backdrops", "Plain", "Black", "Moon", pricing.GiftKey{Name: "Cat", Model: "Plain"}},
+		// an empty list clears the top level one
+		{"cleared", "Plain", "Black", "Moon", pricing.GiftKey{Name: "Cat", Model: "Plain"}},
+		{"cleared", "Gold", "Black", "Moon", pricing.GiftKey{Name: "Cat"}},
+		{"unset", "Plain", "Black", "Moon", pricing.GiftKey{Name: "Cat", Backdrop: "Black"}},
+		{"unset", "Gold", "Blue", "Moon", pricing.GiftKey{Name: "Cat"}},
+	}
+	for _, tt := range tests {
+		got := byName[tt.strategy].Rarity.KeyFor("Cat", tt.model, tt.backdrop, tt.symbol)
+		if got != tt.want {
+			t.Errorf("%s: KeyFor(%s, %s, %s) = %+v, want %+v", tt.strategy, tt.model, tt.backdrop, tt.symbol, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+type Alert struct {
+	SentAt    time.Time `json:"sent_at"`
+	Mode      string    `json:"mode"`
+	Strategy  string    `json:"strategy,omitempty"`
+	Market    string    `json:"market"`
+	GiftID    int       `json:"gift_id,omitempty"`
+	ListingID string    `json:"listing_id,omitempty"`
+	Name      string    `json:"name"`
+	Num       int       `json:"num"`
+	Price     float64   `json:"price"` // bid or listing price
+	Floor     float64   `json:"floor"`
+	Profit    float64   `json:"profit"`
+	Message   string    `json:"message"`
+}
+
+func (db *DB) RecordAlert(a Alert) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		seq, err := tx.Bucket(alertsBucket).NextSequence()
+		if err != nil {
+			return err
+		}
+		return put(tx, alertsBucket, timeKey(a.SentAt, strconv.FormatUint(seq, 10)), a)
+	})
+}
+
+// alerts sent in [from, to), zero to means up to now
+func (db *DB) Alerts(from, to time.Time) ([]Alert, error) {
+	alerts := []Alert{}
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		return scan(tx.Bucket(alertsBucket), from, to, func(raw []byte) error {
+			var a Alert
+			if err := json.Unmarshal(raw, &a); err != nil {
+				return err
+			}
+			alerts = append(alerts, a)
+			return nil
+		})
+	})
+	return alerts, err
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"autobid/tonnel"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+type Snapshot struct {
+	SeenAt time.Time   `json:"seen_at"`
+	Gift   tonnel.Gift `json:"gift"`
+}
+
+// gifts are kept in one bucket per gift id so an auction's history is a single range
+func (db *DB) RecordGifts(gifts []tonnel.Gift, seenAt time.Time) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		for _, g := range gifts {
+			b, err := tx.Bucket(giftsBucket).CreateBucketIfNotExists([]byte(strconv.Itoa(g.GiftID)))
+			if err != nil {
+				return err
+			}
+			raw, err := json.Marshal(Snapshot{SeenAt: seenAt, Gift: g})
+			if err != nil {
+				return err
+			}
+			if err := b.Put(timeKey(seenAt, ""), raw); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (db *DB) GiftSnapshots(giftID int) ([]Snapshot, error) {
+	snapshots := []Snapshot{}
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(giftsBucket).Bucket([]byte(strconv.Itoa(giftID)))
+		if b == nil {
+			return nil
+		}
+		return scan(b, time.Time{}, time.Time{}, func(raw []byte) error {
+			var s Snapshot
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return err
+			}
+			snapshots = append(snapshots, s)
+			return nil
+		})
+	})
+	return snapshots, err
+}
+
+// every recorded gift with its snapshots taken in [from, to), keyed by gift id
+func (db *DB) Snapshots(from, to time.Time) (map[int][]Snapshot, error) {
+	snapshots := map[int][]Snapshot{}
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(giftsBucket).ForEachBucket(func(k []byte) error {
+			giftID, err := strconv.Atoi(string(k))
+			if err != nil {
+				return err
+			}
+			return scan(tx.Bucket(giftsBucket).Bucket(k), from, to, func(raw []byte) error {
+				var s Snapshot
+				if err := json.Unmarshal(raw, &s); err != nil {
+					return err
+				}
+				snapshots[giftID] = append(snapshots[giftID], s)
+				return nil
+			})
+		})
+	})
+	return snapshots, err
+}
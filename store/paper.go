@@ -0,0 +1,30 @@
+package store
+
+import (
+	"autobid/paper"
+	"encoding/json"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// positions are keyed by id, saving one again replaces it
+func (db *DB) SavePosition(p paper.Position) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		return put(tx, paperBucket, []byte(p.ID), p)
+	})
+}
+
+func (db *DB) Positions() ([]paper.Position, error) {
+	positions := []paper.Position{}
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(paperBucket).ForEach(func(k, raw []byte) error {
+			var p paper.Position
+			if err := json.Unmarshal(raw, &p); err != nil {
+				return err
+			}
+			positions = append(positions, p)
+			return nil
+		})
+	})
+	return positions, err
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"bytes"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// deletes gift snapshots, quotes and alerts recorded before before and
+// returns how many, paper positions are kept. bbolt reuses the freed pages
+// so the file stops growing but does not shrink.
+func (db *DB) Prune(before time.Time) (int, error) {
+	n := 0
+	err := db.bolt.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{quotesBucket, alertsBucket} {
+			deleted, err := prune(tx.Bucket(name), before)
+			if err != nil {
+				return err
+			}
+			n += deleted
+		}
+
+		gifts := tx.Bucket(giftsBucket)
+		empty := [][]byte{}
+		err := gifts.ForEachBucket(func(k []byte) error {
+			b := gifts.Bucket(k)
+			deleted, err := prune(b, before)
+			if err != nil {
+				return err
+			}
+			n += deleted
+			if first, _ := b.Cursor().First(); first == nil {
+				empty = append(empty, bytes.Clone(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// buckets can't be deleted while iterating over them
+		for _, k := range empty {
+			if err := gifts.DeleteBucket(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// keys sort by time, so the old records are a prefix of the bucket
+func prune(b *bolt.Bucket, before time.Time) (int, error) {
+	end := string(timeKey(before, ""))
+	keys := [][]byte{}
+	c := b.Cursor()
+	for k, _ := c.First(); k != nil && string(k) < end; k, _ = c.Next() {
+		keys = append(keys, bytes.Clone(k))
+	}
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return 0, err
+		}
+	}
+	return len(keys), nil
+}
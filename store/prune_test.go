@@ -0,0 +1,64 @@
+package store
+
+import (
+	"autobid/pricing"
+	"autobid/tonnel"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPrune(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	old, recent := now.Add(-48*time.Hour), now.Add(-time.Hour)
+	for _, at := range []time.Time{old, recent} {
+		if err := db.RecordGifts([]tonnel.Gift{{GiftID: 2}}, at); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.RecordQuote(pricing.GiftKey{Name: "Cat"}, pricing.Quote{Source: pricing.SourceTonnel, Key: pricing.GiftKey{Name: "Cat"}, Price: 1, Time: at}); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.RecordAlert(Alert{SentAt: at, Name: "Cat"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.RecordGifts([]tonnel.Gift{{GiftID: 1}}, old); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := db.Prune(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("pruned %d records, want 4", n)
+	}
+
+	snapshots, err := db.Snapshots(now.Add(-72*time.Hour), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 || len(snapshots[2]) != 1 || !snapshots[2][0].SeenAt.Equal(recent) {
+		t.Errorf("snapshots left %+v, want the recent one of gift 2", snapshots)
+	}
+	quotes, err := db.QuotesBetween(now.Add(-72*time.Hour), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 1 || !quotes[0].Time.Equal(recent) {
+		t.Errorf("quotes left %+v, want the recent one", quotes)
+	}
+	alerts, err := db.Alerts(now.Add(-72*time.Hour), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || !alerts[0].SentAt.Equal(recent) {
+		t.Errorf("alerts left %+v, want the recent one", alerts)
+	}
+}
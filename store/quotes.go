@@ -0,0 +1,80 @@
+package store
+
+import (
+	"autobid/pricing"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// RecordedQuote is a quote with the key it was looked up for, Key may be
+// wider when the source fell back to the collection floor
+type RecordedQuote struct {
+	pricing.Quote
+	Requested pricing.GiftKey
+}
+
+func (db *DB) RecordQuote(requested pricing.GiftKey, q pricing.Quote) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		return put(tx, quotesBucket, timeKey(q.Time, q.Source+":"+requested.ID()), RecordedQuote{Quote: q, Requested: requested})
+	})
+}
+
+// quotes looked up for key in [from, to), an empty source matches every source
+func (db *DB) Quotes(source string, key pricing.GiftKey, from, to time.Time) ([]RecordedQuote, error) {
+	quotes := []RecordedQuote{}
+	err := db.scanQuotes(from, to, func(q RecordedQuote) {
+		if q.Requested == key && (source == "" || q.Source == source) {
+			quotes = append(quotes, q)
+		}
+	})
+	return quotes, err
+}
+
+// every quote taken in [from, to)
+func (db *DB) QuotesBetween(from, to time.Time) ([]RecordedQuote, error) {
+	quotes := []RecordedQuote{}
+	err := db.scanQuotes(from, to, func(q RecordedQuote) { quotes = append(quotes, q) })
+	return quotes, err
+}
+
+// quotes recorded before the requested key was kept count as requested for their own key
+func (db *DB) scanQuotes(from, to time.Time, fn func(q RecordedQuote)) error {
+	return db.bolt.View(func(tx *bolt.Tx) error {
+		return scan(tx.Bucket(quotesBucket), from, to, func(raw []byte) error {
+			var q RecordedQuote
+			if err := json.Unmarshal(raw, &q); err != nil {
+				return err
+			}
+			if q.Requested == (pricing.GiftKey{}) {
+				q.Requested = q.Key
+			}
+			fn(q)
+			return nil
+		})
+	})
+}
+
+type recordingSource struct {
+	db     *DB
+	source pricing.PriceSource
+}
+
+// records every quote the source returns
+func (db *DB) Recording(source pricing.PriceSource) pricing.PriceSource {
+	return &recordingSource{db: db, source: source}
+}
+
+func (s *recordingSource) Floor(ctx context.Context, key pricing.GiftKey) (pricing.Quote, error) {
+	q, err := s.source.Floor(ctx, key)
+	if err != nil {
+		return q, err
+	}
+	if err := s.db.RecordQuote(key, q); err != nil {
+		slog.Warn("recording quote failed", "source", q.Source, "key", q.Key.String(), "err", err)
+	}
+	return q, nil
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	metaBucket   = []byte("meta")
+	giftsBucket  = []byte("gifts")
+	quotesBucket = []byte("quotes")
+	alertsBucket = []byte("alerts")
+	paperBucket  = []byte("paper")
+
+	versionKey = []byte("version")
+)
+
+// migrations[i] upgrades the schema from version i to i+1, never reorder or edit them
+var migrations = []func(tx *bolt.Tx) error{
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{giftsBucket, quotesBucket, alertsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(paperBucket)
+		return err
+	},
+}
+
+type DB struct {
+	bolt *bolt.DB
+}
+
+func Open(path string) (*DB, error) {
+	b, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	db := &DB{bolt: b}
+	if err := db.migrate(); err != nil {
+		b.Close()
+		return nil, fmt.Errorf("failed to migrate %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// the file lock is shared with other readers but waits for a running writer to close
+func OpenReadOnly(path string) (*DB, error) {
+	b, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	db := &DB{bolt: b}
+	if err := db.checkVersion(); err != nil {
+		b.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *DB) checkVersion() error {
+	return db.bolt.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		if meta == nil || meta.Get(versionKey) == nil {
+			return fmt.Errorf("database is not initialized")
+		}
+		if version := int(binary.BigEndian.Uint64(meta.Get(versionKey))); version != len(migrations) {
+			return fmt.Errorf("schema version %d, expected %d", version, len(migrations))
+		}
+		return nil
+	})
+}
+
+func (db *DB) migrate() error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+
+		version := 0
+		if raw := meta.Get(versionKey); raw != nil {
+			version = int(binary.BigEndian.Uint64(raw))
+		}
+		if version > len(migrations) {
+			return fmt.Errorf("schema version %d is newer than supported %d", version, len(migrations))
+		}
+		for ; version < len(migrations); version++ {
+			if err := migrations[version](tx); err != nil {
+				return fmt.Errorf("migration %d: %w", version+1, err)
+			}
+		}
+
+		return meta.Put(versionKey, binary.BigEndian.AppendUint64(nil, uint64(version)))
+	})
+}
+
+func (db *DB) Close() error {
+	return db.bolt.Close()
+}
+
+// keys sort by time, suffix keeps records at the same instant apart
+func timeKey(t time.Time, suffix string) []byte {
+	return append(binary.BigEndian.AppendUint64(nil, uint64(t.UnixNano())), suffix...)
+}
+
+func put(tx *bolt.Tx, bucket []byte, key []byte, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucket).Put(key, raw)
+}
+
+// calls fn for every record in [from, to) in time order, a zero to means no upper bound
+func scan(b *bolt.Bucket, from, to time.Time, fn func(raw []byte) error) error {
+	c := b.Cursor()
+	var end []byte
+	if !to.IsZero() {
+		end = timeKey(to, "")
+	}
+	for k, v := c.Seek(timeKey(from, "")); k != nil; k, v = c.Next() {
+		if end != nil && string(k) >= string(end) {
+			break
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
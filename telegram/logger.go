@@ -1,14 +1,21 @@
 package telegram
 
 import (
+	"autobid/metrics"
+	"autobid/tracing"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	neturl "net/url"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type TGLogger struct {
@@ -55,7 +62,20 @@ func NewLogger(token string, chatID int64) *TGLogger {
 	}
 }
 
-func (t *TGLogger) SendMessage(ctx context.Context, message string, wait bool, replyTo *int64, markup *InlineKeyboardMarkup) error {
+// WithChat returns a logger sending to chatID with the same token and client
+func (t *TGLogger) WithChat(chatID int64) *TGLogger {
+	c := *t
+	c.ChatID = chatID
+	return &c
+}
+
+func (t *TGLogger) SendMessage(ctx context.Context, message string, wait bool, replyTo *int64, markup *InlineKeyboardMarkup) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "telegram sendMessage", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.Int64("chat_id", t.ChatID),
+		attribute.Bool("retry", !wait),
+	))
+	defer func() { tracing.End(span, err) }()
+
 	payload := sendMessagePayload{
 		Text:                  message,
 		ChatID:                t.ChatID,
@@ -81,19 +101,28 @@ func (t *TGLogger) SendMessage(ctx context.Context, message string, wait bool, r
 
 	resp, err := t.Client.Do(req)
 	if err != nil {
-		return err
+		return withoutURL(err)
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 
 	// Read response body
 	respBody, _ := io.ReadAll(resp.Body)
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		metrics.FloodWait("api.telegram.org", nil)
+	}
+
 	// Handle FloodWait (429)
 	if resp.StatusCode == http.StatusTooManyRequests && wait {
 		var errResp tgErrorResponse
 		if err := json.Unmarshal(respBody, &errResp); err == nil && errResp.Parameters.RetryAfter > 0 {
-			log.Printf("FLOOD WAIT 429: retrying after %d seconds...\n", errResp.Parameters.RetryAfter)
-			time.Sleep(time.Duration(errResp.Parameters.RetryAfter) * time.Second)
+			slog.Warn("flood wait", "origin", "api.telegram.org", "duration", time.Duration(errResp.Parameters.RetryAfter)*time.Second)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(errResp.Parameters.RetryAfter) * time.Second):
+			}
 			// Retry only once
 			return t.SendMessage(ctx, message, false, replyTo, markup)
 		}
@@ -105,3 +134,42 @@ func (t *TGLogger) SendMessage(ctx context.Context, message string, wait bool, r
 
 	return nil
 }
+
+// GetMe checks that the bot token is valid and the API is reachable
+func (t *TGLogger) GetMe(ctx context.Context) (string, error) {
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/getMe", t.Token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("getMe: %w", withoutURL(err))
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+		Result      struct {
+			Username string `json:"username"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("getMe: %w", err)
+	}
+	if !body.Ok {
+		return "", fmt.Errorf("getMe: telegram API error %d: %s", resp.StatusCode, body.Description)
+	}
+	return body.Result.Username, nil
+}
+
+// drops the request url, which contains the token, from client errors
+func withoutURL(err error) error {
+	var urlErr *neturl.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s api.telegram.org: %w", urlErr.Op, urlErr.Err)
+	}
+	return err
+}
@@ -0,0 +1,110 @@
+package telegram
+
+import (
+	"autobid/metrics"
+	"autobid/tracing"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrQueueClosed = errors.New("telegram queue closed")
+var ErrQueueFull = errors.New("telegram queue full")
+
+type Message struct {
+	ChatID  int64 // 0 sends to the logger's chat
+	Text    string
+	ReplyTo *int64
+	Markup  *InlineKeyboardMarkup
+
+	link trace.SpanContext // of the caller, the send is traced separately
+}
+
+// Queue sends messages one at a time in the background.
+type Queue struct {
+	logger *TGLogger
+	ch     chan Message
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewQueue(logger *TGLogger, size int) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		logger: logger,
+		ch:     make(chan Message, size),
+		done:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	go q.run()
+	return q
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+	for msg := range q.ch {
+		ctx, span := tracing.Tracer().Start(q.ctx, "telegram send", trace.WithLinks(trace.Link{SpanContext: msg.link}))
+		logger := q.logger
+		if msg.ChatID != 0 {
+			logger = logger.WithChat(msg.ChatID)
+		}
+		err := logger.SendMessage(ctx, msg.Text, true, msg.ReplyTo, msg.Markup)
+		tracing.End(span, err)
+		if err != nil {
+			metrics.AlertsFailed.WithLabelValues("send").Inc()
+			slog.Error("telegram send failed", "err", err)
+			continue
+		}
+		metrics.AlertsSent.Inc()
+	}
+}
+
+// ctx is only used to link the send to the caller's trace
+func (q *Queue) Send(ctx context.Context, msg Message) error {
+	msg.link = trace.SpanContextFromContext(ctx)
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		metrics.AlertsFailed.WithLabelValues("closed").Inc()
+		return ErrQueueClosed
+	}
+	select {
+	case q.ch <- msg:
+		return nil
+	default:
+		metrics.AlertsFailed.WithLabelValues("full").Inc()
+		return ErrQueueFull
+	}
+}
+
+func (q *Queue) Len() int {
+	return len(q.ch)
+}
+
+// stops accepting messages and sends the queued ones, until ctx is done
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.ch)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		<-q.done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,94 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Update struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *IncomingMessage `json:"message"`
+}
+
+type IncomingMessage struct {
+	MessageID int64 `json:"message_id"`
+	Chat      struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Text string `json:"text"`
+}
+
+// long polling timeout, below the client's
+const pollTimeout = 8 * time.Second
+
+func (t *TGLogger) GetUpdates(ctx context.Context, offset int64) ([]Update, error) {
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/getUpdates?offset=%d&timeout=%d&allowed_updates=%%5B%%22message%%22%%5D", t.Token, offset, int(pollTimeout.Seconds()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("getUpdates: %w", withoutURL(err))
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Ok          bool     `json:"ok"`
+		Description string   `json:"description"`
+		Result      []Update `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("getUpdates: %w", err)
+	}
+	if !body.Ok {
+		return nil, fmt.Errorf("getUpdates: telegram API error %d: %s", resp.StatusCode, body.Description)
+	}
+	return body.Result, nil
+}
+
+// CommandFunc answers a bot command with an HTML message, args is the text after the command
+type CommandFunc func(ctx context.Context, args string) string
+
+// Commands answers commands such as /pnl sent in the given chats until ctx is
+// done, messages from other chats are ignored
+func (t *TGLogger) Commands(ctx context.Context, chats []int64, handlers map[string]CommandFunc) {
+	var offset int64
+	for ctx.Err() == nil {
+		updates, err := t.GetUpdates(ctx, offset)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("fetching telegram updates failed", "err", err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(5 * time.Second):
+				}
+			}
+			continue
+		}
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			m := u.Message
+			if m == nil || !strings.HasPrefix(m.Text, "/") || !slices.Contains(chats, m.Chat.ID) {
+				continue
+			}
+			name, args, _ := strings.Cut(m.Text[1:], " ")
+			name, _, _ = strings.Cut(name, "@") // /pnl@bot in groups
+			handler, ok := handlers[strings.ToLower(name)]
+			if !ok {
+				continue
+			}
+			reply := handler(ctx, strings.TrimSpace(args))
+			if err := t.WithChat(m.Chat.ID).SendMessage(ctx, reply, true, &m.MessageID, nil); err != nil {
+				slog.Warn("answering telegram command failed", "command", name, "err", err)
+			}
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package tlsclient
 
 import (
+	"autobid/logging"
+	"autobid/metrics"
+	"autobid/tracing"
 	"bufio"
 	"bytes"
 	"context"
@@ -8,7 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"math/rand"
 	"net"
 	"net/http"
@@ -19,8 +22,12 @@ import (
 	"golang.org/x/net/proxy"
 
 	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var ErrMaxRetries = errors.New("max retries exceeded")
+
 type FloodWaitError struct {
 	StatusCode int
 	RetryAfter float64
@@ -51,6 +58,7 @@ type TLSClient struct {
 	skipVerify     bool
 	forceReconnect bool
 	proxies        []*url.URL
+	proxy          *url.URL // of the current connection
 	conn           net.Conn
 	tlsConn        *tls.Conn
 }
@@ -110,11 +118,18 @@ func (api *TLSClient) RandomProxy() *url.URL {
 }
 
 func (api *TLSClient) Connect(proxyUrl *url.URL) error {
+	return api.ConnectContext(context.Background(), proxyUrl)
+}
+
+func (api *TLSClient) ConnectContext(ctx context.Context, proxyUrl *url.URL) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
 	addr := api.host + ":443"
 	var conn net.Conn
 	var err error
 	if proxyUrl == nil {
-		conn, err = fasthttp.DialTimeout(addr, 10*time.Second)
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
 		if err != nil {
 			return err
 		}
@@ -123,7 +138,11 @@ func (api *TLSClient) Connect(proxyUrl *url.URL) error {
 		if err != nil {
 			return err
 		}
-		conn, err = dialer.Dial("tcp", addr)
+		if contextDialer, ok := dialer.(proxy.ContextDialer); ok {
+			conn, err = contextDialer.DialContext(ctx, "tcp", addr)
+		} else {
+			conn, err = dialer.Dial("tcp", addr)
+		}
 		if err != nil {
 			return err
 		}
@@ -136,15 +155,26 @@ func (api *TLSClient) Connect(proxyUrl *url.URL) error {
 		cfg = &tls.Config{ServerName: api.host, MinVersion: tls.VersionTLS11}
 	}
 	tlsConn := tls.Client(conn, cfg)
-	if err := tlsConn.Handshake(); err != nil {
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
 		return err
 	}
 
+	if api.conn != nil {
+		api.conn.Close()
+	}
+
 	api.conn = conn
 	api.tlsConn = tlsConn
+	api.proxy = proxyUrl
 	return nil
 }
 
+// returns nil for direct connections
+func (api *TLSClient) Proxy() *url.URL {
+	return api.proxy
+}
+
 func (api *TLSClient) DefaultHeaders(r *http.Request) {
 	for k, v := range map[string]string{
 		"user-agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/117.0.0.0 Safari/537.36",
@@ -162,11 +192,9 @@ func (api *TLSClient) Request(
 	retries uint32,
 	perAttemptTimeout time.Duration,
 ) (*RequestResponse, error) {
-	var i uint32
-
-	for {
+	for i := uint32(0); ; i++ {
 		if i >= retries {
-			return nil, fmt.Errorf("max retries (%d) exceeded", retries)
+			return nil, fmt.Errorf("%w (%d)", ErrMaxRetries, retries)
 		}
 
 		select {
@@ -175,82 +203,131 @@ func (api *TLSClient) Request(
 		default:
 		}
 
-		if body != nil {
-			if _, err := body.Seek(0, io.SeekStart); err != nil {
-				return nil, fmt.Errorf("body.Seek start error: %w", err)
-			}
-		}
-
-		if api.forceReconnect {
-			if err := api.Connect(api.RandomProxy()); err != nil {
-				return nil, err
-			}
+		resp, retry, err := api.attempt(ctx, method, url, body, headers, perAttemptTimeout, i)
+		if retry {
+			continue
 		}
+		return resp, err
+	}
+}
 
-		var err error
-		var request *http.Request
-		if body == nil {
-			request, err = http.NewRequest(method, url, nil)
-		} else {
-			request, err = http.NewRequest(method, url, body)
+// one try of Request, retry is set when the connection was replaced and the request should be sent again
+func (api *TLSClient) attempt(
+	ctx context.Context,
+	method, url string,
+	body io.ReadSeeker,
+	headers map[string]string,
+	perAttemptTimeout time.Duration,
+	i uint32,
+) (resp *RequestResponse, retry bool, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "HTTP "+method, trace.WithSpanKind(trace.SpanKindClient))
+	defer func() {
+		span.SetAttributes(attribute.String("proxy", metrics.ProxyLabel(api.proxy)), attribute.Bool("retry", retry))
+		if resp != nil {
+			span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 		}
-		if err != nil {
-			return nil, err
+		tracing.End(span, err)
+	}()
+	span.SetAttributes(
+		attribute.String("server.address", api.host),
+		attribute.String("url.path", pathOf(url)),
+		attribute.Int("attempt", int(i)),
+	)
+
+	if body != nil {
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return nil, false, fmt.Errorf("body.Seek start error: %w", err)
 		}
+	}
 
-		api.DefaultHeaders(request)
-		for k, v := range headers {
-			request.Header.Set(k, v)
+	if api.forceReconnect {
+		if err := api.ConnectContext(ctx, api.RandomProxy()); err != nil {
+			return nil, false, err
 		}
+	}
+
+	var request *http.Request
+	if body == nil {
+		request, err = http.NewRequest(method, url, nil)
+	} else {
+		request, err = http.NewRequest(method, url, body)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	api.DefaultHeaders(request)
+	for k, v := range headers {
+		request.Header.Set(k, v)
+	}
+
+	byteRep := func() []byte {
+		r := &bytes.Buffer{}
+		request.Write(r)
+		return r.Bytes()
+	}()
 
-		byteRep := func() []byte {
-			r := &bytes.Buffer{}
-			request.Write(r)
-			return r.Bytes()
-		}()
-
-		deadline := time.Now().Add(perAttemptTimeout)
-		api.tlsConn.SetDeadline(deadline)
-
-		if _, err := api.tlsConn.Write(byteRep); err != nil {
-			if IsConnectionAbortedError(err) {
-				log.Printf("INFO: Http Client Reconnect Requested after write error: %v\n", err)
-				if err := api.Connect(api.RandomProxy()); err != nil {
-					return nil, err
-				}
-				i++
-				continue
+	deadline := time.Now().Add(perAttemptTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	api.tlsConn.SetDeadline(deadline)
+	// unblocks the write and read below once ctx is canceled
+	stop := context.AfterFunc(ctx, func() {
+		api.tlsConn.SetDeadline(time.Now())
+	})
+
+	if _, err := api.tlsConn.Write(byteRep); err != nil {
+		stop()
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+		if IsConnectionAbortedError(err) {
+			slog.Info("reconnecting after write error", "host", api.host, logging.Proxy(api.proxy), "err", err)
+			if err := api.ConnectContext(ctx, api.RandomProxy()); err != nil {
+				return nil, false, err
 			}
-			return nil, err
+			return nil, true, nil
 		}
+		return nil, false, err
+	}
 
-		reader := bufio.NewReader(api.tlsConn)
+	reader := bufio.NewReader(api.tlsConn)
 
-		res := fasthttp.AcquireResponse()
-		defer fasthttp.ReleaseResponse(res)
+	res := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(res)
 
-		err = res.Read(reader)
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				log.Printf("INFO: Http Client Reconnect Requested after EOF read: %v\n", err)
-				if err := api.Connect(api.RandomProxy()); err != nil {
-					return nil, err
-				}
-				i++
-				continue
+	err = res.Read(reader)
+	stop()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+		if errors.Is(err, io.EOF) {
+			slog.Info("reconnecting after EOF", "host", api.host, logging.Proxy(api.proxy), "err", err)
+			if err := api.ConnectContext(ctx, api.RandomProxy()); err != nil {
+				return nil, false, err
 			}
-			return nil, err
+			return nil, true, nil
 		}
+		return nil, false, err
+	}
 
-		rawBody := res.Body()
-		full := &RequestResponse{
-			StatusCode: res.StatusCode(),
-			Ok:         Ok(res.StatusCode()),
-			Body:       append([]byte(nil), rawBody...),
-		}
+	rawBody := res.Body()
+	return &RequestResponse{
+		StatusCode: res.StatusCode(),
+		Ok:         Ok(res.StatusCode()),
+		Body:       append([]byte(nil), rawBody...),
+	}, false, nil
+}
 
-		return full, nil
+// path without the query, which may carry credentials
+func pathOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
 	}
+	return u.Path
 }
 
 func (api *TLSClient) Close() error {
@@ -1,13 +1,16 @@
 package tonnel
 
 import (
+	"autobid/logging"
+	"autobid/metrics"
 	"autobid/tlsclient"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/url"
+	"regexp"
 	"time"
 )
 
@@ -100,6 +103,22 @@ type Gift struct {
 	Price              float64                `json:"price,omitempty"` // optional field
 }
 
+// highest bid of a finished auction, 0 if nobody bid
+func (g *Gift) FinalBid() float64 {
+	if g.Auction == nil || len(g.Auction.BidHistory) == 0 {
+		return 0
+	}
+	return g.Auction.BidHistory[len(g.Auction.BidHistory)-1].Amount
+}
+
+// price the gift was sold for, winning bid for auctions
+func (g *Gift) SalePrice() float64 {
+	if g.Auction != nil {
+		return g.FinalBid()
+	}
+	return g.Price
+}
+
 func (g *Gift) MinBid() float64 {
 	bid_step := 0.05
 	if len(g.Auction.BidHistory) > 0 {
@@ -112,97 +131,98 @@ func (g *Gift) MinBid() float64 {
 }
 
 func (api *TonnelAPI) GetFloor(ctx context.Context, giftName string, model string, backdrop string) (*Gift, error) {
-	url := "https://rs-gifts.tonnel.network/api/pageGifts"
-
-	headers := make(map[string]string, len(DEFAULT_HEADERS))
-	for k, v := range DEFAULT_HEADERS {
-		headers[k] = v
+	gifts, err := api.SearchListings(ctx, giftName, model, backdrop, 30)
+	if err != nil {
+		return nil, err
 	}
-	headers["Content-Type"] = "application/json"
 
-	filterMap := map[string]interface{}{
-		"price":     map[string]interface{}{"$exists": true},
-		"buyer":     map[string]interface{}{"$exists": false},
-		"gift_name": giftName,
-		"asset":     "TON",
-	}
-	if len(model) > 0 {
-		filterMap["model"] = model
-	}
-	if len(backdrop) > 0 {
-		filterMap["backdrop"] = map[string]interface{}{"$in": []string{backdrop}}
+	if len(gifts) < 1 {
+		return nil, nil
 	}
-	filterBytes, err := json.Marshal(filterMap)
+
+	return &gifts[0], nil
+}
+
+// cheapest fixed-price listings first
+func (api *TonnelAPI) SearchListings(ctx context.Context, giftName string, model string, backdrop string, limit uint32) ([]Gift, error) {
+	filter, err := giftFilter(map[string]interface{}{
+		"price": map[string]interface{}{"$exists": true},
+		"buyer": map[string]interface{}{"$exists": false},
+	}, giftName, model, backdrop)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal filter: %w", err)
+		return nil, err
 	}
-	bodyStruct := RequestBody{
+
+	return api.pageGifts(ctx, RequestBody{
 		Page:       1,
-		Limit:      30,
+		Limit:      limit,
 		Sort:       `{"price":1,"gift_id":-1}`,
-		Filter:     string(filterBytes),
+		Filter:     filter,
 		Ref:        0,
 		PriceRange: nil,
 		UserAuth:   "",
-	}
-	bodyBytes, err := json.Marshal(bodyStruct)
+	})
+}
+
+// most recently ended auctions first
+func (api *TonnelAPI) GetFinishedAuctions(ctx context.Context, giftName string, model string, backdrop string, limit uint32) ([]Gift, error) {
+	filter, err := giftFilter(map[string]interface{}{
+		"auction_id": map[string]interface{}{"$exists": true},
+		"status":     map[string]interface{}{"$ne": "active"},
+	}, giftName, model, backdrop)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		return nil, err
 	}
 
-	var resp *tlsclient.RequestResponse
-	i := uint32(0)
-	t := FLOOD_WAIT
-	for {
-		resp, err = api.conn.Request(ctx, "POST", url, bytes.NewReader(bodyBytes), headers, 3, 60*time.Second)
-		if err != nil {
-			return nil, err
-		}
-
-		if !resp.Ok {
-			if resp.StatusCode == 429 {
-				i += 1
-				if i > api.opt.FloodRetries {
-					return nil, &tlsclient.FloodWaitError{
-						StatusCode: 429,
-						RetryAfter: t.Seconds(),
-						Origin:     "rs-gifts.tonnel.network",
-					}
-				}
-
-				log.Printf("INFO: rs-gifts.tonnel.network returned 429, waiting for %f secs...", t.Seconds())
-				time.Sleep(t)
-				t *= 2
-				continue
-			}
+	return api.pageGifts(ctx, RequestBody{
+		Page:       1,
+		Limit:      limit,
+		Sort:       `{"auctionEndTime":-1,"gift_id":-1}`,
+		Filter:     filter,
+		Ref:        0,
+		PriceRange: nil,
+		UserAuth:   "",
+	})
+}
 
-			return nil, fmt.Errorf("%d: %s", resp.StatusCode, string(resp.Body))
-		}
+// completed fixed-price sales and won auctions, most recent first
+func (api *TonnelAPI) GetSales(ctx context.Context, page uint32, limit uint32) ([]Gift, error) {
+	return api.pageGifts(ctx, RequestBody{
+		Page:       page,
+		Limit:      limit,
+		Sort:       `{"message_post_time":-1,"gift_id":-1}`,
+		Filter:     `{"buyer":{"$exists":true},"status":{"$ne":"active"},"asset":"TON"}`,
+		Ref:        0,
+		PriceRange: nil,
+		UserAuth:   "",
+	})
+}
 
-		break
+func giftFilter(filterMap map[string]interface{}, giftName string, model string, backdrop string) (string, error) {
+	filterMap["gift_name"] = giftName
+	filterMap["asset"] = "TON"
+	if len(model) > 0 {
+		filterMap["model"] = traitFilter(model)
 	}
-
-	var gifts []Gift
-	if err := json.Unmarshal(resp.Body, &gifts); err != nil {
-		return nil, fmt.Errorf("error parsing JSON: %v", err)
+	if len(backdrop) > 0 {
+		filterMap["backdrop"] = traitFilter(backdrop)
 	}
-
-	if len(gifts) < 1 {
-		return nil, nil
+	filterBytes, err := json.Marshal(filterMap)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal filter: %w", err)
 	}
+	return string(filterBytes), nil
+}
 
-	return &gifts[0], nil
+// matches the trait name with any rarity suffix, other markets round rarities
+// differently than tonnel
+func traitFilter(trait string) map[string]interface{} {
+	name := regexp.QuoteMeta(ParseTrait(trait).Name)
+	return map[string]interface{}{"$regex": `^` + name + `(\s*\([^)]*%\))?$`}
 }
 
 func (api *TonnelAPI) GetAuctions(ctx context.Context, page uint32, limit uint32) ([]Gift, error) {
-	url := "https://rs-gifts.tonnel.network/api/pageGifts"
-
-	headers := make(map[string]string, len(DEFAULT_HEADERS))
-	for k, v := range DEFAULT_HEADERS {
-		headers[k] = v
-	}
-	headers["Content-Type"] = "application/json"
-	bodyStruct := RequestBody{
+	return api.pageGifts(ctx, RequestBody{
 		Page:       page,
 		Limit:      limit,
 		Sort:       `{"auctionEndTime":1,"gift_id":-1}`,
@@ -210,7 +230,30 @@ func (api *TonnelAPI) GetAuctions(ctx context.Context, page uint32, limit uint32
 		Ref:        0,
 		PriceRange: nil,
 		UserAuth:   "",
+	})
+}
+
+// newest fixed-price listings first
+func (api *TonnelAPI) GetListings(ctx context.Context, page uint32, limit uint32) ([]Gift, error) {
+	return api.pageGifts(ctx, RequestBody{
+		Page:       page,
+		Limit:      limit,
+		Sort:       `{"message_post_time":-1,"gift_id":-1}`,
+		Filter:     `{"price":{"$exists":true},"buyer":{"$exists":false},"asset":"TON"}`,
+		Ref:        0,
+		PriceRange: nil,
+		UserAuth:   "",
+	})
+}
+
+func (api *TonnelAPI) pageGifts(ctx context.Context, bodyStruct RequestBody) ([]Gift, error) {
+	url := "https://rs-gifts.tonnel.network/api/pageGifts"
+
+	headers := make(map[string]string, len(DEFAULT_HEADERS))
+	for k, v := range DEFAULT_HEADERS {
+		headers[k] = v
 	}
+	headers["Content-Type"] = "application/json"
 	bodyBytes, err := json.Marshal(bodyStruct)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request body: %w", err)
@@ -227,6 +270,7 @@ func (api *TonnelAPI) GetAuctions(ctx context.Context, page uint32, limit uint32
 
 		if !resp.Ok {
 			if resp.StatusCode == 429 {
+				metrics.FloodWait("rs-gifts.tonnel.network", api.conn.Proxy())
 				i += 1
 				if i > api.opt.FloodRetries {
 					return nil, &tlsclient.FloodWaitError{
@@ -236,8 +280,12 @@ func (api *TonnelAPI) GetAuctions(ctx context.Context, page uint32, limit uint32
 					}
 				}
 
-				log.Printf("INFO: rs-gifts.tonnel.network returned 429, waiting for %f secs...", t.Seconds())
-				time.Sleep(t)
+				slog.Warn("flood wait", "origin", "rs-gifts.tonnel.network", logging.Proxy(api.conn.Proxy()), "duration", t)
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(t):
+				}
 				t *= 2
 				continue
 			}
@@ -255,3 +303,7 @@ func (api *TonnelAPI) GetAuctions(ctx context.Context, page uint32, limit uint32
 
 	return gifts, nil
 }
+
+func (api *TonnelAPI) Close() error {
+	return api.conn.Close()
+}
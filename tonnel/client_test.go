@@ -0,0 +1,42 @@
+package tonnel
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+)
+
+// traits match tonnel's names whatever rarity another market reports
+func TestGiftFilterTraits(t *testing.T) {
+	raw, err := giftFilter(map[string]interface{}{}, "Plush Pepe", "Onyx Black (1.25%)", "Black")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var filter struct {
+		Model struct {
+			Regex string `json:"$regex"`
+		} `json:"model"`
+		Backdrop struct {
+			Regex string `json:"$regex"`
+		} `json:"backdrop"`
+	}
+	if err := json.Unmarshal([]byte(raw), &filter); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		re    string
+		trait string
+		want  bool
+	}{
+		{filter.Model.Regex, "Onyx Black (1.2%)", true},
+		{filter.Model.Regex, "Onyx Black", true},
+		{filter.Model.Regex, "Onyx Blackout (1.2%)", false},
+		{filter.Backdrop.Regex, "Black (2%)", true},
+		{filter.Backdrop.Regex, "Onyx Black (2%)", false},
+	} {
+		if got := regexp.MustCompile(tt.re).MatchString(tt.trait); got != tt.want {
+			t.Errorf("%q matching %q: %v, want %v", tt.re, tt.trait, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package tonnel
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// rarity suffix tonnel appends to trait names, e.g. "Onyx Black (2%)"
+var raritySuffix = regexp.MustCompile(`\s*\(\s*(\d+(?:\.\d+)?)\s*%\s*\)\s*$`)
+
+type Trait struct {
+	Name   string
+	Rarity float64 // percent of the collection sharing the trait, 0 if unknown
+}
+
+func ParseTrait(s string) Trait {
+	m := raritySuffix.FindStringSubmatchIndex(s)
+	if m == nil {
+		return Trait{Name: strings.TrimSpace(s)}
+	}
+	rarity, err := strconv.ParseFloat(s[m[2]:m[3]], 64)
+	if err != nil {
+		return Trait{Name: strings.TrimSpace(s)}
+	}
+	return Trait{Name: strings.TrimSpace(s[:m[0]]), Rarity: rarity}
+}
+
+func (t Trait) String() string {
+	if t.Rarity <= 0 {
+		return t.Name
+	}
+	return fmt.Sprintf("%s (%s%%)", t.Name, strconv.FormatFloat(t.Rarity, 'f', -1, 64))
+}
+
+// CombinedRarity is the percent of the collection expected to share all
+// traits, assuming they are independent. 0 if any rarity is unknown.
+func CombinedRarity(traits ...Trait) float64 {
+	if len(traits) == 0 {
+		return 0
+	}
+	combined := 100.0
+	for _, t := range traits {
+		if t.Rarity <= 0 {
+			return 0
+		}
+		combined *= t.Rarity / 100
+	}
+	return combined
+}
+
+func (g *Gift) ModelTrait() Trait {
+	return ParseTrait(g.Model)
+}
+
+func (g *Gift) BackdropTrait() Trait {
+	return ParseTrait(g.Backdrop)
+}
+
+func (g *Gift) SymbolTrait() Trait {
+	return ParseTrait(g.Symbol)
+}
+
+// Rarity is the combined rarity of the model, backdrop and symbol
+func (g *Gift) Rarity() float64 {
+	return CombinedRarity(g.ModelTrait(), g.BackdropTrait(), g.SymbolTrait())
+}
@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"net"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RedisHook traces every command and pipeline sent to addr
+func RedisHook(addr string) redis.Hook {
+	return redisHook{attrs: []attribute.KeyValue{
+		attribute.String("db.system", "redis"),
+		attribute.String("server.address", addr),
+	}}
+}
+
+type redisHook struct {
+	attrs []attribute.KeyValue
+}
+
+func (h redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		ctx, span := Tracer().Start(ctx, "redis dial", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(h.attrs...))
+		conn, err := next(ctx, network, addr)
+		End(span, err)
+		return conn, err
+	}
+}
+
+func (h redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := Tracer().Start(ctx, "redis "+cmd.Name(), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(h.attrs...))
+		err := next(ctx, cmd)
+		End(span, ignoreNil(err))
+		return err
+	}
+}
+
+func (h redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := Tracer().Start(ctx, "redis pipeline", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(h.attrs...))
+		span.SetAttributes(attribute.Int("db.redis.commands", len(cmds)))
+		err := next(ctx, cmds)
+		End(span, ignoreNil(err))
+		return err
+	}
+}
+
+// a missing key is not a failure
+func ignoreNil(err error) error {
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
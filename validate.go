@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// checks config.json and the env without starting the bot, every problem is printed
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("config validate", flag.ExitOnError)
+	flags := newConfigFlags(fs)
+	fs.Parse(args)
+
+	cfg, err := flags.load()
+	if err != nil {
+		problems := strings.Split(err.Error(), "\n")
+		fmt.Fprintf(os.Stderr, "%d problem(s) in %s/config.json:\n", len(problems), *flags.dir)
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "  - %s\n", p)
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("config ok: mode %s, %d proxies, floor sources %s\n", cfg.Mode, len(cfg.Proxies), strings.Join(cfg.FloorSources, ", "))
+}